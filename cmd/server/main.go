@@ -1,85 +1,359 @@
 package main
 
 import (
+    "context"
+    "errors"
     "fmt"
     "log"
+    "log/slog"
+    "math"
     "net/http"
+    "os"
+    "os/signal"
+    "sync"
+    "syscall"
+    "time"
+    _ "time/tzdata" // Time zone preferences work without system zone data
     
     "github.com/golang-migrate/migrate/v4"
     "github.com/golang-migrate/migrate/v4/database/postgres"
     _ "github.com/golang-migrate/migrate/v4/source/file"
     "github.com/gorilla/mux"
     
+    "github.com/bryan/finance-tracker/internal/config"
     "github.com/bryan/finance-tracker/internal/database"
     "github.com/bryan/finance-tracker/internal/handlers"
+    "github.com/bryan/finance-tracker/internal/metrics"
     "github.com/bryan/finance-tracker/internal/middleware"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/ratelimit"
+    "github.com/bryan/finance-tracker/internal/storage"
 )
 
 const (
     migrationURL = "file://migrations"
-    port         = ":4000"
 )
 
 func main() {
+    // Load configuration
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatalf("Invalid configuration: %v", err)
+    }
+    
+    // Send all logging, including the standard log package, through slog
+    slog.SetDefault(newLogger(cfg))
+    
     // Connect to the database
     if err := database.Connect(); err != nil {
         log.Fatalf("Database connection failed: %v", err)
     }
-    defer database.Close()
     
     // Initialize templates
     if err := handlers.InitTemplates(); err != nil {
+        database.Close()
         log.Fatalf("Error initializing templates: %v", err)
     }
     
+    // Attachments are kept on the local filesystem
+    store, err := storage.NewLocal(cfg.StorageDir)
+    if err != nil {
+        database.Close()
+        log.Fatalf("Error initializing attachment storage: %v", err)
+    }
+    models.SetBlobStore(store)
+    
     // Run migrations
-    if err := runMigrations(); err != nil {
+    m, err := runMigrations()
+    if err != nil {
+        database.Close()
         log.Fatalf("Error running migrations: %v", err)
     }
     
+    // Readiness compares the live schema version with the one we started on
+    expectedVersion, _, err := m.Version()
+    if err != nil {
+        database.Close()
+        log.Fatalf("Error reading migration version: %v", err)
+    }
+    handlers.ConfigureReadiness(cfg.ReadyTimeout, expectedVersion)
+    
+    // Dates and amounts follow the saved preferences
+    if err := models.LoadPreferences(); err != nil {
+        database.Close()
+        log.Fatalf("Error loading preferences: %v", err)
+    }
+    
     // Create router
     r := mux.NewRouter()
-    r.Use(middleware.LoggingMiddleware)
+    r.NotFoundHandler = http.HandlerFunc(handlers.NotFoundHandler)
+    r.Use(middleware.RouteTemplate)
+    
+    // Background workers are started with workerCtx and tracked by workers,
+    // so shutdown can wait for them before the database is closed
+    workerCtx, stopWorkers := context.WithCancel(context.Background())
+    var workers sync.WaitGroup
+    runWorker := func(fn func(ctx context.Context)) {
+        workers.Add(1)
+        go func() {
+            defer workers.Done()
+            fn(workerCtx)
+        }()
+    }
     
     // Static files
     staticDir := http.Dir("./static")
     staticHandler := http.StripPrefix("/static/", http.FileServer(staticDir))
     r.PathPrefix("/static/").Handler(staticHandler)
     
+    // Health checks
+    r.HandleFunc("/healthz", handlers.HealthzHandler).Methods("GET")
+    r.HandleFunc("/readyz", handlers.ReadyzHandler).Methods("GET")
+    
+    // Prometheus metrics
+    registerMetrics()
+    r.Handle("/metrics", metrics.Handler()).Methods("GET")
+    
+    // Requests are limited per client IP; there is no login or API token to
+    // limit by yet
+    ipLimiter := ratelimit.New(cfg.RateLimitIP, time.Minute, cfg.RateLimitBurst)
+    runWorker(func(ctx context.Context) { ipLimiter.Run(ctx, time.Minute) })
+    
+    app := r.PathPrefix("/").Subrouter()
+    app.Use(middleware.RateLimit(ipLimiter, middleware.ByIP))
+    
     // Dashboard routes
-    r.HandleFunc("/", handlers.DashboardHandler).Methods("GET")
+    app.HandleFunc("/", handlers.DashboardHandler).Methods("GET")
     
     // Transaction routes
-    r.HandleFunc("/transactions", handlers.ListTransactionsHandler).Methods("GET")
-    r.HandleFunc("/transactions/new", handlers.GetTransactionFormHandler).Methods("GET")
-    r.HandleFunc("/transactions", handlers.CreateTransactionHandler).Methods("POST")
-    r.HandleFunc("/transactions/{id:[0-9]+}/edit", handlers.GetTransactionEditHandler).Methods("GET")
-    r.HandleFunc("/transactions/{id:[0-9]+}", handlers.UpdateTransactionHandler).Methods("POST")
-    r.HandleFunc("/transactions/{id:[0-9]+}/delete", handlers.DeleteTransactionHandler).Methods("POST")
+    app.HandleFunc("/transactions", handlers.ListTransactionsHandler).Methods("GET")
+    app.HandleFunc("/transactions/new", handlers.GetTransactionFormHandler).Methods("GET")
+    app.HandleFunc("/transactions", handlers.CreateTransactionHandler).Methods("POST")
+    app.HandleFunc("/transactions/bulk", handlers.BulkTransactionsHandler).Methods("POST")
+    app.HandleFunc("/transactions/suggest", handlers.SuggestCategoryHandler).Methods("GET")
+    app.HandleFunc("/transactions/{id:[0-9]+}/edit", handlers.GetTransactionEditHandler).Methods("GET")
+    app.HandleFunc("/transactions/{id:[0-9]+}", handlers.UpdateTransactionHandler).Methods("POST")
+    app.HandleFunc("/transactions/{id:[0-9]+}/delete", handlers.DeleteTransactionHandler).Methods("POST")
+    
+    // Attachment routes
+    handlers.SetMaxUploadSize(int64(cfg.MaxUploadBytes))
+    app.HandleFunc("/transactions/{id:[0-9]+}/attachments", handlers.UploadAttachmentHandler).Methods("POST")
+    app.HandleFunc("/transactions/{id:[0-9]+}/attachments/{attachment:[0-9]+}", handlers.DownloadAttachmentHandler).Methods("GET")
+    app.HandleFunc("/transactions/{id:[0-9]+}/attachments/{attachment:[0-9]+}/thumbnail", handlers.AttachmentThumbnailHandler).Methods("GET")
+    app.HandleFunc("/transactions/{id:[0-9]+}/attachments/{attachment:[0-9]+}/delete", handlers.DeleteAttachmentHandler).Methods("POST")
+    
+    // Account routes
+    app.HandleFunc("/accounts", handlers.AccountsHandler).Methods("GET")
+    app.HandleFunc("/accounts", handlers.CreateAccountHandler).Methods("POST")
+    
+    // Payee routes
+    app.HandleFunc("/payees", handlers.PayeesHandler).Methods("GET")
+    app.HandleFunc("/payees", handlers.CreatePayeeHandler).Methods("POST")
+    app.HandleFunc("/payees/{id:[0-9]+}", handlers.PayeeHandler).Methods("GET")
+    app.HandleFunc("/payees/{id:[0-9]+}/aliases", handlers.AddPayeeAliasHandler).Methods("POST")
+    app.HandleFunc("/payees/{id:[0-9]+}/aliases/delete", handlers.DeletePayeeAliasHandler).Methods("POST")
+    app.HandleFunc("/payees/{id:[0-9]+}/merge", handlers.MergePayeeHandler).Methods("POST")
+    
+    // Savings goal routes
+    app.HandleFunc("/goals", handlers.GoalsHandler).Methods("GET")
+    app.HandleFunc("/goals", handlers.CreateGoalHandler).Methods("POST")
+    app.HandleFunc("/goals/{id:[0-9]+}", handlers.GoalHandler).Methods("GET")
+    app.HandleFunc("/goals/{id:[0-9]+}", handlers.UpdateGoalHandler).Methods("POST")
+    app.HandleFunc("/goals/{id:[0-9]+}/delete", handlers.DeleteGoalHandler).Methods("POST")
+    app.HandleFunc("/goals/{id:[0-9]+}/contributions", handlers.LinkContributionHandler).Methods("POST")
+    app.HandleFunc("/goals/{id:[0-9]+}/contributions/{transaction:[0-9]+}/delete", handlers.UnlinkContributionHandler).Methods("POST")
+    
+    // Net worth routes
+    app.HandleFunc("/networth", handlers.NetWorthHandler).Methods("GET")
+    app.HandleFunc("/networth/snapshots", handlers.SaveSnapshotHandler).Methods("POST")
+    app.HandleFunc("/networth/snapshots/{id:[0-9]+}/delete", handlers.DeleteSnapshotHandler).Methods("POST")
+    
+    // Forecast routes
+    app.HandleFunc("/forecast", handlers.ForecastHandler).Methods("GET")
+    app.HandleFunc("/recurring", handlers.RecurringItemsHandler).Methods("GET")
+    app.HandleFunc("/recurring/new", handlers.NewRecurringItemHandler).Methods("GET")
+    app.HandleFunc("/recurring", handlers.SaveRecurringItemHandler).Methods("POST")
+    app.HandleFunc("/recurring/{id:[0-9]+}/edit", handlers.EditRecurringItemHandler).Methods("GET")
+    app.HandleFunc("/recurring/{id:[0-9]+}", handlers.SaveRecurringItemHandler).Methods("POST")
+    app.HandleFunc("/recurring/{id:[0-9]+}/delete", handlers.DeleteRecurringItemHandler).Methods("POST")
+    
+    // Report routes
+    app.HandleFunc("/reports/trends", handlers.TrendReportHandler).Methods("GET")
+    app.HandleFunc("/reports/trends.json", handlers.TrendReportJSONHandler).Methods("GET")
+    app.HandleFunc("/reports/categories", handlers.CategoryReportHandler).Methods("GET")
+    app.HandleFunc("/reports/pivot.json", handlers.PivotJSONHandler).Methods("GET")
+    app.HandleFunc("/reports/pivot.csv", handlers.PivotCSVHandler).Methods("GET")
+    
+    // Rule routes
+    app.HandleFunc("/rules", handlers.RulesHandler).Methods("GET")
+    app.HandleFunc("/rules/new", handlers.NewRuleHandler).Methods("GET")
+    app.HandleFunc("/rules", handlers.SaveRuleHandler).Methods("POST")
+    app.HandleFunc("/rules/{id:[0-9]+}/edit", handlers.EditRuleHandler).Methods("GET")
+    app.HandleFunc("/rules/{id:[0-9]+}", handlers.SaveRuleHandler).Methods("POST")
+    app.HandleFunc("/rules/{id:[0-9]+}/delete", handlers.DeleteRuleHandler).Methods("POST")
+    app.HandleFunc("/rules/{id:[0-9]+}/apply", handlers.ApplyRuleHandler).Methods("POST")
+    
+    // Exchange rate routes
+    app.HandleFunc("/exchange-rates", handlers.ExchangeRatesHandler).Methods("GET")
+    app.HandleFunc("/exchange-rates", handlers.SaveExchangeRateHandler).Methods("POST")
+    app.HandleFunc("/exchange-rates/import", handlers.ImportExchangeRatesHandler).Methods("POST")
+    app.HandleFunc("/exchange-rates/{id:[0-9]+}/delete", handlers.DeleteExchangeRateHandler).Methods("POST")
+    
+    // Preference routes
+    app.HandleFunc("/preferences", handlers.PreferencesHandler).Methods("GET")
+    app.HandleFunc("/preferences", handlers.SavePreferencesHandler).Methods("POST")
+    
+    // Trash routes
+    handlers.SetTrashRetention(cfg.TrashRetention)
+    app.HandleFunc("/trash", handlers.TrashHandler).Methods("GET")
+    app.HandleFunc("/trash/{id:[0-9]+}/restore", handlers.RestoreTransactionHandler).Methods("POST")
+    app.HandleFunc("/trash/{id:[0-9]+}/purge", handlers.PurgeTransactionHandler).Methods("POST")
+    if cfg.TrashRetention > 0 {
+        runWorker(func(ctx context.Context) { purgeTrash(ctx, cfg.TrashRetention) })
+    }
+
+    // The router's Use only runs once a route matches, so middleware that
+    // must see every request, 404 and 405 responses included, wraps it
+    var handler http.Handler = r
+    handler = middleware.Compress(handler)
+    handler = middleware.SecurityHeaders(cfg.HSTSMaxAge)(handler)
+    handler = middleware.Recover(handlers.ServerErrorHandler)(handler)
+    handler = middleware.Metrics(handler)
+    handler = middleware.LoggingMiddleware(handler)
+    handler = middleware.RealIP(cfg.TrustedProxies)(handler)
+    handler = middleware.RequestID(handler)
 
+    srv := &http.Server{
+        Addr:              cfg.Addr,
+        Handler:           handler,
+        ReadTimeout:       cfg.ReadTimeout,
+        ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+        WriteTimeout:      cfg.WriteTimeout,
+        IdleTimeout:       cfg.IdleTimeout,
+        MaxHeaderBytes:    cfg.MaxHeaderBytes,
+    }
+    
+    // Stop on SIGINT (Ctrl+C) or SIGTERM (process supervisor)
+    sigCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stopSignals()
     
     // Start server
-    fmt.Printf("Server starting on port %s\n", port)
-    log.Fatal(http.ListenAndServe(port, r))
+    serverErr := make(chan error, 1)
+    go func() {
+        slog.Info("Server starting", "addr", cfg.Addr)
+        if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            serverErr <- err
+        }
+        close(serverErr)
+    }()
+    
+    exitCode := 0
+    select {
+    case err := <-serverErr:
+        if err != nil {
+            slog.Error("Server error", "err", err)
+            exitCode = 1
+        }
+    case <-sigCtx.Done():
+        slog.Info("Shutdown signal received, draining connections")
+    }
+    
+    // Restore default signal handling so a second Ctrl+C kills the process
+    stopSignals()
+    
+    // Stop accepting new connections and wait for in-flight requests
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+    defer cancel()
+    if err := srv.Shutdown(shutdownCtx); err != nil {
+        slog.Error("Graceful shutdown did not complete", "err", err)
+        srv.Close()
+        exitCode = 1
+    }
+    
+    // Stop background workers before the database goes away. Closing the
+    // migrate instance also closes database.DB, since the postgres driver
+    // was built on top of it, so the pool is not closed a second time.
+    stopWorkers()
+    workers.Wait()
+    if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+        slog.Error("Closing the database failed", "source_err", srcErr, "db_err", dbErr)
+    }
+    
+    slog.Info("Server stopped")
+    os.Exit(exitCode)
 }
 
-func runMigrations() error {
+// purgeTrash permanently removes transactions that have been in the trash
+// longer than retention, checking once an hour until ctx is cancelled
+func purgeTrash(ctx context.Context, retention time.Duration) {
+    ticker := time.NewTicker(time.Hour)
+    defer ticker.Stop()
+    
+    for {
+        purged, err := models.PurgeDeletedBefore(time.Now().Add(-retention), "system")
+        if err != nil {
+            slog.Error("Purging trash failed", "err", err)
+        } else if purged > 0 {
+            slog.Info("Purged expired transactions from trash", "count", purged)
+        }
+        
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// registerMetrics exposes database pool statistics and business gauges
+func registerMetrics() {
+    metrics.RegisterDBStats(database.DB)
+    
+    metrics.NewGaugeFunc("finance_transactions_created_today", "Transactions entered since midnight in the preferred time zone.", func() float64 {
+        now := models.Now()
+        midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+        count, err := models.CountTransactionsCreatedSince(midnight)
+        if err != nil {
+            slog.Warn("Could not count today's transactions", "err", err)
+            return math.NaN()
+        }
+        return float64(count)
+    })
+}
+
+// newLogger builds the application logger from the configured level and format
+func newLogger(cfg *config.Config) *slog.Logger {
+    opts := &slog.HandlerOptions{Level: cfg.LogLevel}
+    
+    var handler slog.Handler
+    if cfg.LogFormat == "json" {
+        handler = slog.NewJSONHandler(os.Stdout, opts)
+    } else {
+        handler = slog.NewTextHandler(os.Stdout, opts)
+    }
+    
+    return slog.New(handler)
+}
+
+// runMigrations applies pending migrations and returns the migrate instance
+// so the schema version can be checked later
+func runMigrations() (*migrate.Migrate, error) {
     db := database.DB
     driver, err := postgres.WithInstance(db, &postgres.Config{})
     if err != nil {
-        return fmt.Errorf("could not create migration driver: %v", err)
+        return nil, fmt.Errorf("could not create migration driver: %v", err)
     }
     
     m, err := migrate.NewWithDatabaseInstance(
         migrationURL,
         "postgres", driver)
     if err != nil {
-        return fmt.Errorf("could not create migrate instance: %v", err)
+        return nil, fmt.Errorf("could not create migrate instance: %v", err)
     }
     
     if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-        return fmt.Errorf("could not run migrations: %v", err)
+        return nil, fmt.Errorf("could not run migrations: %v", err)
     }
     
-    return nil
-}
\ No newline at end of file
+    return m, nil
+}
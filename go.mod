@@ -3,6 +3,7 @@ module github.com/bryan/finance-tracker
 go 1.23.4
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/golang-migrate/migrate/v4 v4.17.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -0,0 +1,58 @@
+package charts
+
+import (
+    "fmt"
+    "html"
+    "html/template"
+    "strings"
+)
+
+// BarChart draws one group of bars per label, a bar for each series. Bars
+// below zero also get the "negative" class.
+type BarChart struct {
+    Width, Height int
+    Labels        []string
+    Series        []Series
+    Format        func(float64) string // Axis labels and tooltips; Money when nil
+}
+
+// SVG renders the chart. Without labels it renders nothing.
+func (c BarChart) SVG() template.HTML {
+    if len(c.Labels) == 0 || len(c.Series) == 0 {
+        return ""
+    }
+
+    low, high := valueRange(c.Series)
+    p := newPlot(c.Width, c.Height, low, high, c.Format)
+
+    var b strings.Builder
+    p.open(&b, "bar-chart")
+    p.yAxis(&b)
+
+    // Each group takes an equal slot with a fifth of it left as a gap
+    slot := float64(p.plotWidth()) / float64(len(c.Labels))
+    barWidth := slot * 0.8 / float64(len(c.Series))
+    p.xLabels(&b, c.Labels, func(i int) float64 { return marginLeft + (float64(i)+0.5)*slot })
+
+    zero := p.y(0)
+    for si, s := range c.Series {
+        for i, v := range s.Values {
+            if i >= len(c.Labels) {
+                break
+            }
+            x := marginLeft + float64(i)*slot + slot*0.1 + float64(si)*barWidth
+            top, height := p.y(v), zero-p.y(v)
+            class := s.Class
+            if v < 0 {
+                top, height = zero, p.y(v)-zero
+                class += " negative"
+            }
+            fmt.Fprintf(&b, `<rect class="chart-bar %s" x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s %s: %s</title></rect>`,
+                html.EscapeString(class), x, top, barWidth, height,
+                html.EscapeString(s.Name), html.EscapeString(c.Labels[i]), html.EscapeString(p.format(v)))
+        }
+    }
+
+    b.WriteString(`</svg>`)
+    return template.HTML(b.String())
+}
@@ -0,0 +1,188 @@
+// Package charts renders small SVG charts on the server, so reports work
+// without JavaScript under the Content-Security-Policy. Colours come from
+// the CSS classes on each element.
+package charts
+
+import (
+    "fmt"
+    "html"
+    "html/template"
+    "math"
+    "strings"
+)
+
+// Chart margins leave room for the axis labels
+const (
+    marginLeft   = 64
+    marginRight  = 16
+    marginTop    = 16
+    marginBottom = 28
+)
+
+// Series is one line or set of bars. Class is added to its SVG elements.
+type Series struct {
+    Name   string
+    Class  string
+    Values []float64
+}
+
+// LineChart plots each series as a line over evenly spaced labels
+type LineChart struct {
+    Width, Height int
+    Labels        []string
+    Series        []Series
+    Format        func(float64) string // Axis labels; Money when nil
+}
+
+// SVG renders the chart. With fewer than two points it renders nothing.
+func (c LineChart) SVG() template.HTML {
+    if len(c.Labels) < 2 {
+        return ""
+    }
+
+    low, high := valueRange(c.Series)
+    p := newPlot(c.Width, c.Height, low, high, c.Format)
+
+    var b strings.Builder
+    p.open(&b, "line-chart")
+    p.yAxis(&b)
+
+    step := float64(p.plotWidth()) / float64(len(c.Labels)-1)
+    p.xLabels(&b, c.Labels, func(i int) float64 { return marginLeft + float64(i)*step })
+
+    for _, s := range c.Series {
+        var points []string
+        for i, v := range s.Values {
+            points = append(points, fmt.Sprintf("%.1f,%.1f", marginLeft+float64(i)*step, p.y(v)))
+        }
+        fmt.Fprintf(&b, `<polyline class="chart-line %s" fill="none" points="%s"><title>%s</title></polyline>`,
+            html.EscapeString(s.Class), strings.Join(points, " "), html.EscapeString(s.Name))
+    }
+
+    b.WriteString(`</svg>`)
+    return template.HTML(b.String())
+}
+
+// plot maps values to SVG coordinates
+type plot struct {
+    width, height int
+    low, high     float64
+    format        func(float64) string
+}
+
+func newPlot(width, height int, low, high float64, format func(float64) string) plot {
+    if format == nil {
+        format = Money
+    }
+    return plot{width: width, height: height, low: low, high: high, format: format}
+}
+
+func (p plot) plotWidth() int  { return p.width - marginLeft - marginRight }
+func (p plot) plotHeight() int { return p.height - marginTop - marginBottom }
+
+func (p plot) y(v float64) float64 {
+    return marginTop + (p.high-v)/(p.high-p.low)*float64(p.plotHeight())
+}
+
+func (p plot) open(b *strings.Builder, class string) {
+    fmt.Fprintf(b, `<svg class="chart %s" viewBox="0 0 %d %d" role="img" xmlns="http://www.w3.org/2000/svg">`, class, p.width, p.height)
+}
+
+// yAxis draws horizontal grid lines with their values, plus a stronger
+// line at zero when zero is in range
+func (p plot) yAxis(b *strings.Builder) {
+    for _, v := range ticks(p.low, p.high, 5) {
+        y := p.y(v)
+        class := "chart-grid"
+        if v == 0 {
+            class = "chart-zero"
+        }
+        fmt.Fprintf(b, `<line class="%s" x1="%d" y1="%.1f" x2="%d" y2="%.1f"/>`, class, marginLeft, y, p.width-marginRight, y)
+        fmt.Fprintf(b, `<text class="chart-label" x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`,
+            marginLeft-6, y, html.EscapeString(p.format(v)))
+    }
+}
+
+// xLabels writes at most about eight labels so they do not overlap
+func (p plot) xLabels(b *strings.Builder, labels []string, x func(int) float64) {
+    every := int(math.Ceil(float64(len(labels)) / 8))
+    for i, label := range labels {
+        if i%every != 0 {
+            continue
+        }
+        // Keep a label at the right edge inside the chart
+        anchor := "middle"
+        if x(i) > float64(p.width-marginRight-32) {
+            anchor = "end"
+        }
+        fmt.Fprintf(b, `<text class="chart-label" x="%.1f" y="%d" text-anchor="%s">%s</text>`,
+            x(i), p.height-marginBottom+18, anchor, html.EscapeString(label))
+    }
+}
+
+// valueRange returns the rounded axis range covering every value and zero
+func valueRange(series []Series) (float64, float64) {
+    low, high := 0.0, 0.0
+    for _, s := range series {
+        for _, v := range s.Values {
+            low = math.Min(low, v)
+            high = math.Max(high, v)
+        }
+    }
+    if low == high {
+        high = low + 1
+    }
+
+    t := ticks(low, high, 5)
+    return t[0], t[len(t)-1]
+}
+
+// ticks returns round values spanning low to high, about n of them
+func ticks(low, high float64, n int) []float64 {
+    span := high - low
+    if span <= 0 {
+        return []float64{low, low + 1}
+    }
+
+    step := math.Pow(10, math.Floor(math.Log10(span/float64(n))))
+    for _, m := range []float64{1, 2, 5, 10} {
+        if span/(step*m) <= float64(n) {
+            step *= m
+            break
+        }
+    }
+
+    // Step past high so the top tick is at or above it
+    var values []float64
+    for v := math.Floor(low/step) * step; ; v += step {
+        // Round away float noise such as 0.30000000000000004
+        v = math.Round(v/step) * step
+        values = append(values, v)
+        if v >= high {
+            return values
+        }
+    }
+}
+
+// Percent formats a percentage for axis labels: 25%, -10%
+func Percent(v float64) string {
+    return fmt.Sprintf("%.0f%%", v)
+}
+
+// Money formats an amount compactly for axis labels: $950, $12.5k, -$1.2M
+func Money(v float64) string {
+    sign := ""
+    if v < 0 {
+        sign = "-"
+    }
+    v = math.Abs(v)
+
+    switch {
+    case v >= 1e6:
+        return sign + "$" + strings.TrimSuffix(fmt.Sprintf("%.1f", v/1e6), ".0") + "M"
+    case v >= 1e3:
+        return sign + "$" + strings.TrimSuffix(fmt.Sprintf("%.1f", v/1e3), ".0") + "k"
+    default:
+        return sign + "$" + fmt.Sprintf("%.0f", v)
+    }
+}
@@ -0,0 +1,99 @@
+package charts
+
+import (
+    "fmt"
+    "html"
+    "html/template"
+    "math"
+    "strings"
+)
+
+// Slice is one part of a donut chart. When URL is set the slice links there.
+type Slice struct {
+    Name  string
+    Class string
+    Value float64
+    URL   string
+}
+
+// DonutChart draws slices as a ring with a label in the middle
+type DonutChart struct {
+    Size   int
+    Slices []Slice
+    Label  string               // Shown in the centre, such as the total
+    Format func(float64) string // Tooltips; Money when nil
+}
+
+// SVG renders the chart. Slices that are not positive are skipped, and
+// with nothing to show it renders nothing.
+func (c DonutChart) SVG() template.HTML {
+    var total float64
+    for _, s := range c.Slices {
+        if s.Value > 0 {
+            total += s.Value
+        }
+    }
+    if total <= 0 {
+        return ""
+    }
+
+    format := c.Format
+    if format == nil {
+        format = Money
+    }
+
+    centre := float64(c.Size) / 2
+    outer := centre - 2
+    inner := outer * 0.6
+
+    var b strings.Builder
+    fmt.Fprintf(&b, `<svg class="chart donut-chart" viewBox="0 0 %d %d" role="img" xmlns="http://www.w3.org/2000/svg">`, c.Size, c.Size)
+
+    // Angles start at the top and run clockwise
+    angle := -math.Pi / 2
+    for _, s := range c.Slices {
+        if s.Value <= 0 {
+            continue
+        }
+        sweep := s.Value / total * 2 * math.Pi
+
+        var path string
+        if sweep >= 2*math.Pi-1e-9 {
+            // A single arc cannot close a full circle, so draw two halves
+            path = ringPath(centre, outer, inner, angle, angle+math.Pi) + " " + ringPath(centre, outer, inner, angle+math.Pi, angle+2*math.Pi)
+        } else {
+            path = ringPath(centre, outer, inner, angle, angle+sweep)
+        }
+        angle += sweep
+
+        title := fmt.Sprintf("%s: %s (%.0f%%)", s.Name, format(s.Value), s.Value/total*100)
+        slice := fmt.Sprintf(`<path class="chart-slice %s" d="%s"><title>%s</title></path>`,
+            html.EscapeString(s.Class), path, html.EscapeString(title))
+        if s.URL != "" {
+            slice = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(s.URL), slice)
+        }
+        b.WriteString(slice)
+    }
+
+    if c.Label != "" {
+        fmt.Fprintf(&b, `<text class="chart-centre" x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle">%s</text>`,
+            centre, centre, html.EscapeString(c.Label))
+    }
+
+    b.WriteString(`</svg>`)
+    return template.HTML(b.String())
+}
+
+// ringPath outlines the part of a ring between two angles
+func ringPath(centre, outer, inner, from, to float64) string {
+    large := 0
+    if to-from > math.Pi {
+        large = 1
+    }
+    point := func(r, a float64) string {
+        return fmt.Sprintf("%.2f %.2f", centre+r*math.Cos(a), centre+r*math.Sin(a))
+    }
+    return fmt.Sprintf("M %s A %.2f %.2f 0 %d 1 %s L %s A %.2f %.2f 0 %d 0 %s Z",
+        point(outer, from), outer, outer, large, point(outer, to),
+        point(inner, to), inner, inner, large, point(inner, from))
+}
@@ -0,0 +1,114 @@
+// Package classifier implements a small multinomial naive Bayes classifier
+// over string features, used to suggest categories for new transactions.
+package classifier
+
+import (
+    "math"
+    "sort"
+)
+
+// NaiveBayes counts how often each feature occurs with each label. The zero
+// value is not usable; create one with New.
+type NaiveBayes struct {
+    docs          map[int]int            // training examples per label
+    featureCounts map[int]map[string]int // feature occurrences per label
+    featureTotals map[int]int            // all feature occurrences per label
+    vocabulary    map[string]bool
+    total         int
+}
+
+// Prediction is a label with its posterior probability
+type Prediction struct {
+    Label       int
+    Probability float64
+}
+
+// New returns an untrained classifier
+func New() *NaiveBayes {
+    return &NaiveBayes{
+        docs:          make(map[int]int),
+        featureCounts: make(map[int]map[string]int),
+        featureTotals: make(map[int]int),
+        vocabulary:    make(map[string]bool),
+    }
+}
+
+// Train adds one labelled example
+func (nb *NaiveBayes) Train(label int, features []string) {
+    nb.docs[label]++
+    nb.total++
+
+    counts := nb.featureCounts[label]
+    if counts == nil {
+        counts = make(map[string]int)
+        nb.featureCounts[label] = counts
+    }
+    for _, f := range features {
+        counts[f]++
+        nb.featureTotals[label]++
+        nb.vocabulary[f] = true
+    }
+}
+
+// Examples returns the number of training examples seen
+func (nb *NaiveBayes) Examples() int {
+    return nb.total
+}
+
+// Known reports how many of the features were seen during training
+func (nb *NaiveBayes) Known(features []string) int {
+    known := 0
+    for _, f := range features {
+        if nb.vocabulary[f] {
+            known++
+        }
+    }
+    return known
+}
+
+// Predict returns every label ordered from most to least likely. Feature
+// likelihoods use add-one smoothing; features never seen in training are
+// ignored so they do not flatten the result.
+func (nb *NaiveBayes) Predict(features []string) []Prediction {
+    if nb.total == 0 {
+        return nil
+    }
+
+    vocab := float64(len(nb.vocabulary))
+    scores := make([]Prediction, 0, len(nb.docs))
+    best := math.Inf(-1)
+
+    for label, docs := range nb.docs {
+        score := math.Log(float64(docs) / float64(nb.total))
+        denominator := float64(nb.featureTotals[label]) + vocab
+        for _, f := range features {
+            if !nb.vocabulary[f] {
+                continue
+            }
+            score += math.Log((float64(nb.featureCounts[label][f]) + 1) / denominator)
+        }
+        scores = append(scores, Prediction{Label: label, Probability: score})
+        if score > best {
+            best = score
+        }
+    }
+
+    // Turn log scores into probabilities, shifting by the best score so
+    // the exponentials do not underflow
+    sum := 0.0
+    for i := range scores {
+        scores[i].Probability = math.Exp(scores[i].Probability - best)
+        sum += scores[i].Probability
+    }
+    for i := range scores {
+        scores[i].Probability /= sum
+    }
+
+    sort.Slice(scores, func(i, j int) bool {
+        if scores[i].Probability != scores[j].Probability {
+            return scores[i].Probability > scores[j].Probability
+        }
+        return scores[i].Label < scores[j].Label
+    })
+    return scores
+}
@@ -0,0 +1,92 @@
+package classifier
+
+import (
+    "math"
+    "testing"
+)
+
+const (
+    groceries = 1
+    fuel      = 2
+    salary    = 3
+)
+
+func trained() *NaiveBayes {
+    nb := New()
+    nb.Train(groceries, []string{"tesco", "stores"})
+    nb.Train(groceries, []string{"tesco", "express"})
+    nb.Train(groceries, []string{"aldi"})
+    nb.Train(fuel, []string{"shell", "station"})
+    nb.Train(fuel, []string{"bp", "station"})
+    nb.Train(salary, []string{"acme", "payroll"})
+    return nb
+}
+
+func TestPredict(t *testing.T) {
+    nb := trained()
+
+    tests := []struct {
+        name      string
+        features  []string
+        wantLabel int
+    }{
+        {"seen word", []string{"tesco"}, groceries},
+        {"shared word", []string{"station"}, fuel},
+        {"mixed words lean to the stronger", []string{"shell", "station", "tesco"}, fuel},
+        {"unknown words fall back to the prior", []string{"unheard"}, groceries},
+        {"unknown words are ignored", []string{"unheard", "acme", "payroll"}, salary},
+        {"no features fall back to the prior", nil, groceries},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            predictions := nb.Predict(tt.features)
+            if len(predictions) != 3 {
+                t.Fatalf("got %d predictions, want one per label", len(predictions))
+            }
+            if predictions[0].Label != tt.wantLabel {
+                t.Errorf("best label = %d, want %d", predictions[0].Label, tt.wantLabel)
+            }
+
+            sum := 0.0
+            for i, p := range predictions {
+                sum += p.Probability
+                if i > 0 && p.Probability > predictions[i-1].Probability {
+                    t.Errorf("predictions are not ordered by probability: %v", predictions)
+                }
+            }
+            if math.Abs(sum-1) > 1e-9 {
+                t.Errorf("probabilities sum to %v, want 1", sum)
+            }
+        })
+    }
+}
+
+func TestPredictUntrained(t *testing.T) {
+    if predictions := New().Predict([]string{"tesco"}); predictions != nil {
+        t.Errorf("untrained classifier predicted %v", predictions)
+    }
+}
+
+func TestKnown(t *testing.T) {
+    nb := trained()
+
+    tests := []struct {
+        features []string
+        want     int
+    }{
+        {nil, 0},
+        {[]string{"unheard"}, 0},
+        {[]string{"tesco", "unheard"}, 1},
+        {[]string{"tesco", "station", "payroll"}, 3},
+    }
+
+    for _, tt := range tests {
+        if got := nb.Known(tt.features); got != tt.want {
+            t.Errorf("Known(%v) = %d, want %d", tt.features, got, tt.want)
+        }
+    }
+    if got := nb.Examples(); got != 6 {
+        t.Errorf("Examples() = %d, want 6", got)
+    }
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+    "fmt"
+    "log/slog"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Config holds the runtime settings of the application
+type Config struct {
+    // HTTP server
+    Addr              string
+    ReadTimeout       time.Duration
+    ReadHeaderTimeout time.Duration
+    WriteTimeout      time.Duration
+    IdleTimeout       time.Duration
+    MaxHeaderBytes    int
+    ShutdownTimeout   time.Duration
+    ReadyTimeout      time.Duration
+    HSTSMaxAge        time.Duration
+
+    // Logging
+    LogLevel       slog.Level
+    LogFormat      string // "text" or "json"
+    TrustedProxies []*net.IPNet
+
+    // Rate limiting, in requests per minute
+    RateLimitIP    int
+    RateLimitBurst int
+
+    // Deleted transactions older than this are purged; zero disables purging
+    TrashRetention time.Duration
+
+    // Attachments
+    StorageDir     string
+    MaxUploadBytes int
+}
+
+// Load reads the configuration from environment variables, falling back to defaults
+func Load() (*Config, error) {
+    cfg := &Config{
+        Addr: getEnv("APP_ADDR", ":4000"),
+    }
+
+    var err error
+
+    if cfg.ReadTimeout, err = getDuration("APP_READ_TIMEOUT", 10*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.ReadHeaderTimeout, err = getDuration("APP_READ_HEADER_TIMEOUT", 5*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.WriteTimeout, err = getDuration("APP_WRITE_TIMEOUT", 30*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.IdleTimeout, err = getDuration("APP_IDLE_TIMEOUT", 120*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.MaxHeaderBytes, err = getInt("APP_MAX_HEADER_BYTES", 1<<20); err != nil {
+        return nil, err
+    }
+
+    if cfg.ShutdownTimeout, err = getDuration("APP_SHUTDOWN_TIMEOUT", 15*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.ReadyTimeout, err = getDuration("APP_READY_TIMEOUT", 2*time.Second); err != nil {
+        return nil, err
+    }
+
+    if cfg.HSTSMaxAge, err = getDuration("APP_HSTS_MAX_AGE", 365*24*time.Hour); err != nil {
+        return nil, err
+    }
+
+    if err = cfg.LogLevel.UnmarshalText([]byte(getEnv("APP_LOG_LEVEL", "info"))); err != nil {
+        return nil, fmt.Errorf("invalid APP_LOG_LEVEL: %v", err)
+    }
+
+    cfg.LogFormat = strings.ToLower(getEnv("APP_LOG_FORMAT", "text"))
+    if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+        return nil, fmt.Errorf("invalid APP_LOG_FORMAT %q: must be text or json", cfg.LogFormat)
+    }
+
+    if cfg.TrustedProxies, err = getNetworks("APP_TRUSTED_PROXIES"); err != nil {
+        return nil, err
+    }
+
+    if cfg.RateLimitIP, err = getInt("APP_RATE_LIMIT_IP", 300); err != nil {
+        return nil, err
+    }
+
+    if cfg.RateLimitBurst, err = getInt("APP_RATE_LIMIT_BURST", 60); err != nil {
+        return nil, err
+    }
+
+    if cfg.TrashRetention, err = getDuration("APP_TRASH_RETENTION", 30*24*time.Hour); err != nil {
+        return nil, err
+    }
+
+    cfg.StorageDir = getEnv("APP_STORAGE_DIR", "data/attachments")
+
+    if cfg.MaxUploadBytes, err = getInt("APP_MAX_UPLOAD_BYTES", 10<<20); err != nil {
+        return nil, err
+    }
+
+    return cfg, nil
+}
+
+// getEnv returns the value of an environment variable or the fallback if unset
+func getEnv(key, fallback string) string {
+    if value, ok := os.LookupEnv(key); ok && value != "" {
+        return value
+    }
+    return fallback
+}
+
+// getDuration parses an environment variable as a time.Duration such as "30s"
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+    value := getEnv(key, "")
+    if value == "" {
+        return fallback, nil
+    }
+
+    d, err := time.ParseDuration(value)
+    if err != nil || d < 0 {
+        return 0, fmt.Errorf("invalid duration for %s: %q", key, value)
+    }
+    return d, nil
+}
+
+// getInt parses an environment variable as a non-negative integer
+func getInt(key string, fallback int) (int, error) {
+    value := getEnv(key, "")
+    if value == "" {
+        return fallback, nil
+    }
+
+    n, err := strconv.Atoi(value)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid integer for %s: %q", key, value)
+    }
+    return n, nil
+}
+
+// getNetworks parses a comma-separated list of IP addresses and CIDR ranges
+func getNetworks(key string) ([]*net.IPNet, error) {
+    var networks []*net.IPNet
+
+    for _, item := range strings.Split(getEnv(key, ""), ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+
+        // Treat a bare address as a single-host range
+        if !strings.Contains(item, "/") {
+            ip := net.ParseIP(item)
+            if ip == nil {
+                return nil, fmt.Errorf("invalid address in %s: %q", key, item)
+            }
+            bits := 32
+            if ip.To4() == nil {
+                bits = 128
+            }
+            item = fmt.Sprintf("%s/%d", item, bits)
+        }
+
+        _, network, err := net.ParseCIDR(item)
+        if err != nil {
+            return nil, fmt.Errorf("invalid network in %s: %q", key, item)
+        }
+        networks = append(networks, network)
+    }
+
+    return networks, nil
+}
@@ -3,7 +3,7 @@ package database
 import (
     "database/sql"
     "fmt"
-    "log"
+    "log/slog"
     "regexp"
     "strings"
     "time"
@@ -44,7 +44,7 @@ func Connect() error {
     db.SetConnMaxLifetime(5 * time.Minute)
     
     DB = db
-    log.Println("Successfully connected to the database")
+    slog.Info("Successfully connected to the database")
     return nil
 }
 
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// accountsData is passed to accounts.html
+type accountsData struct {
+    Accounts     []models.Account
+    AccountTypes []string
+    Currencies   []locale.Currency
+    Account      models.Account
+    Validator    *validator.Validator
+}
+
+// AccountsHandler lists the accounts with a form to add another
+func AccountsHandler(w http.ResponseWriter, r *http.Request) {
+    renderAccounts(w, r, http.StatusOK, models.Account{Type: "checking", Currency: models.GetPreferences().Currency}, validator.NewValidator())
+}
+
+// CreateAccountHandler handles the submission of a new account
+func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    account := &models.Account{
+        Name:     r.FormValue("name"),
+        Type:     r.FormValue("type"),
+        Currency: models.ParseCurrency(r.FormValue("currency")),
+    }
+
+    v := validator.NewValidator()
+    models.ValidateAccount(v, account)
+
+    if v.ValidData() {
+        err := account.Create()
+        if err == nil {
+            http.Redirect(w, r, "/accounts", http.StatusSeeOther)
+            return
+        }
+        if !errors.Is(err, models.ErrConflict) {
+            renderError(w, r, err)
+            return
+        }
+        v.AddError("name", "An account with this name already exists")
+    }
+
+    renderAccounts(w, r, http.StatusUnprocessableEntity, *account, v)
+}
+
+func renderAccounts(w http.ResponseWriter, r *http.Request, status int, account models.Account, v *validator.Validator) {
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderStatus(w, status, "accounts.html", accountsData{
+        Accounts:     accounts,
+        AccountTypes: models.AccountTypes,
+        Currencies:   locale.Currencies,
+        Account:      account,
+        Validator:    v,
+    })
+}
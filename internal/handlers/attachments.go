@@ -0,0 +1,187 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "mime"
+    "net/http"
+    "strconv"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/thumbnail"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// thumbnailSize is the largest width or height of an image preview
+const thumbnailSize = 200
+
+// maxUploadSize is the largest attachment accepted, in bytes
+var maxUploadSize int64 = 10 << 20
+
+// SetMaxUploadSize configures the largest attachment accepted
+func SetMaxUploadSize(size int64) {
+    maxUploadSize = size
+}
+
+// UploadAttachmentHandler attaches an uploaded receipt or document to a
+// transaction. The type is detected from the file contents, and images get
+// a thumbnail.
+func UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+    transaction, ok := transactionFromRequest(w, r)
+    if !ok {
+        return
+    }
+
+    v := validator.NewValidator()
+
+    // Leave room for the multipart framing around the file
+    r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+64<<10)
+    file, header, err := r.FormFile("attachment")
+    var tooLarge *http.MaxBytesError
+    switch {
+    case errors.As(err, &tooLarge):
+        v.AddError("attachment", fmt.Sprintf("Files cannot be larger than %s", models.Attachment{Size: maxUploadSize}.SizeString()))
+    case err != nil:
+        v.AddError("attachment", "Please choose a file to upload")
+    }
+    if !v.ValidData() {
+        renderTransactionForm(w, r, "transaction_edit.html", &transaction, v)
+        return
+    }
+    defer file.Close()
+
+    // Read one byte past the limit so oversized files are detected
+    data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    attachment := &models.Attachment{
+        TransactionID: transaction.ID,
+        Filename:      models.CleanFilename(header.Filename),
+        ContentType:   http.DetectContentType(data),
+        Size:          int64(len(data)),
+    }
+    attachment.ContentType, _, _ = mime.ParseMediaType(attachment.ContentType)
+
+    models.ValidateAttachment(v, attachment, maxUploadSize)
+    if !v.ValidData() {
+        renderTransactionForm(w, r, "transaction_edit.html", &transaction, v)
+        return
+    }
+
+    // A thumbnail is a convenience; the upload succeeds without one
+    var thumb []byte
+    switch attachment.ContentType {
+    case "image/jpeg", "image/png", "image/gif":
+        thumb, err = thumbnail.Generate(data, thumbnailSize)
+        if err != nil {
+            slog.Warn("Generating thumbnail failed", "filename", attachment.Filename, "err", err)
+        }
+    }
+
+    if err := attachment.Create(data, thumb, actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/transactions/%d/edit", transaction.ID), http.StatusSeeOther)
+}
+
+// DownloadAttachmentHandler sends an attachment as a download
+func DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+    serveAttachment(w, r, false)
+}
+
+// AttachmentThumbnailHandler sends the preview image of an attachment
+func AttachmentThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+    serveAttachment(w, r, true)
+}
+
+// DeleteAttachmentHandler removes an attachment
+func DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+    attachment, ok := attachmentFromRequest(w, r)
+    if !ok {
+        return
+    }
+
+    if err := attachment.Delete(actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/transactions/%d/edit", attachment.TransactionID), http.StatusSeeOther)
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, thumb bool) {
+    attachment, ok := attachmentFromRequest(w, r)
+    if !ok {
+        return
+    }
+
+    file, err := attachment.Open(thumb)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    defer file.Close()
+
+    h := w.Header()
+    h.Set("Cache-Control", "private, max-age=3600")
+    if thumb {
+        h.Set("Content-Type", thumbnail.ContentType)
+    } else {
+        h.Set("Content-Type", attachment.ContentType)
+        h.Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+        h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+    }
+
+    if _, err := io.Copy(w, file); err != nil {
+        slog.Warn("Sending attachment failed", "id", attachment.ID, "err", err)
+    }
+}
+
+// transactionFromRequest loads the live transaction named by the {id} route
+// variable, rendering the error page if it cannot
+func transactionFromRequest(w http.ResponseWriter, r *http.Request) (models.Transaction, bool) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return models.Transaction{}, false
+    }
+
+    transaction, err := models.GetTransactionByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return models.Transaction{}, false
+    }
+    return transaction, true
+}
+
+// attachmentFromRequest loads the attachment named by the {id} and
+// {attachment} route variables
+func attachmentFromRequest(w http.ResponseWriter, r *http.Request) (models.Attachment, bool) {
+    vars := mux.Vars(r)
+    transactionID, err := strconv.Atoi(vars["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return models.Attachment{}, false
+    }
+    id, err := strconv.Atoi(vars["attachment"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return models.Attachment{}, false
+    }
+
+    attachment, err := models.GetAttachment(transactionID, id)
+    if err != nil {
+        renderError(w, r, err)
+        return models.Attachment{}, false
+    }
+    return attachment, true
+}
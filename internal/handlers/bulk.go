@@ -0,0 +1,97 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// BulkTransactionsHandler applies one action to every selected transaction
+// and shows a summary of what changed
+func BulkTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+
+    // Collect the selected IDs, ignoring duplicates
+    var ids []int
+    seen := map[int]bool{}
+    for _, value := range r.PostForm["ids"] {
+        id, err := strconv.Atoi(value)
+        if err != nil || id <= 0 {
+            v.AddError("ids", "Invalid transaction selection")
+            continue
+        }
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+
+    op := models.BulkOperation{
+        Action: r.PostForm.Get("action"),
+        Tag:    r.PostForm.Get("tag"),
+    }
+
+    if op.Action == models.BulkRecategorize {
+        op.CategoryID, _ = strconv.Atoi(r.PostForm.Get("category_id"))
+    }
+
+    if op.Action == models.BulkShiftDate {
+        days, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get("days")))
+        if err != nil {
+            v.AddError("days", "Days must be a whole number")
+        }
+        op.Days = days
+    }
+
+    models.ValidateBulkOperation(v, op, ids)
+
+    // Make sure the target category still exists
+    if v.ValidData() && op.Action == models.BulkRecategorize {
+        if _, err := models.GetCategoryByID(op.CategoryID); errors.Is(err, models.ErrNotFound) {
+            v.AddError("category_id", "The selected category no longer exists")
+        } else if err != nil {
+            renderError(w, r, err)
+            return
+        }
+    }
+
+    if !v.ValidData() {
+        renderStatus(w, http.StatusUnprocessableEntity, "bulk_result.html", bulkResultData{
+            Result:    &models.BulkResult{Operation: op},
+            Validator: v,
+        })
+        return
+    }
+
+    result, err := models.ApplyBulk(ids, op, actorFromRequest(r))
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    status := http.StatusOK
+    if !result.Applied {
+        status = http.StatusUnprocessableEntity
+    }
+
+    renderStatus(w, status, "bulk_result.html", bulkResultData{
+        Result:    result,
+        Validator: v,
+    })
+}
+
+// bulkResultData is passed to bulk_result.html
+type bulkResultData struct {
+    Result    *models.BulkResult
+    Validator *validator.Validator
+    Rule      *models.Rule // set when a rule was applied retroactively
+}
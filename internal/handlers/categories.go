@@ -26,7 +26,7 @@ func GetCategoryFormHandler(w http.ResponseWriter, r *http.Request) {
 func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
     // Parse form data
     if err := r.ParseForm(); err != nil {
-        http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
         return
     }
     
@@ -55,8 +55,8 @@ func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
     }
     
     // Save category to database
-    if err := category.Create(); err != nil {
-        http.Error(w, "Error creating category: "+err.Error(), http.StatusInternalServerError)
+    if err := category.Create(actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
         return
     }
     
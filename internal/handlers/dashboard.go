@@ -1,38 +1,173 @@
 package handlers
 
 import (
+    "errors"
+    "math"
     "net/http"
+    "net/url"
+    "strings"
     "time"
     
     "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
 )
 
+// dashboardData is passed to dashboard.html
+type dashboardData struct {
+    Summary            map[string]float64
+    Cards              []summaryCard
+    Period             models.Period
+    PreviousPeriod     models.Period
+    YearAgoPeriod      models.Period
+    PeriodOptions      []periodOption
+    PreviousURL        string
+    NextURL            string
+    Transactions       []models.Transaction
+    RecentTransactions []models.Transaction
+    Goals              []models.Goal
+    Validator          *validator.Validator
+}
+
+// periodOption is one choice in a period or date range picker
+type periodOption struct {
+    Kind  string
+    Label string
+}
+
+// periodOptions lists the period kinds models.NewPeriod accepts, and custom
+var periodOptions = []periodOption{
+    {"budget", "Budget period"},
+    {"month", "Month"},
+    {"quarter", "Quarter"},
+    {"year", "Year"},
+    {"30d", "Last 30 days"},
+    {"90d", "Last 90 days"},
+    {"custom", "Custom"},
+}
+
+// dateRangeOptions labels the presets models.DateRangePreset accepts, for
+// the filter forms
+var dateRangeOptions = []periodOption{
+    {"this_period", "This budget period"},
+    {"last_period", "Last budget period"},
+    {"this_month", "This month"},
+    {"last_month", "Last month"},
+    {"ytd", "Year to date"},
+    {"last_12_months", "Last 12 months"},
+    {"last_7_days", "Last 7 days"},
+}
+
+// summaryCard is a dashboard total with its change against the previous
+// period and the same period a year before
+type summaryCard struct {
+    Title    string
+    Class    string
+    Amount   float64
+    Previous summaryChange
+    YearAgo  summaryChange
+}
+
+// summaryChange compares an amount with the same total from an earlier period
+type summaryChange struct {
+    Amount         float64 // The earlier total
+    Change         float64
+    HigherIsBetter bool
+}
+
+// ChangeString formats the change in the preferred currency, such as
+// "+$120.00"
+func (c summaryChange) ChangeString() string {
+    sign := ""
+    if c.Change >= 0 {
+        sign = "+"
+    }
+    return sign + models.GetPreferences().Formatter().Money(c.Change)
+}
+
+// PercentString formats the change as a percentage of the earlier total, or
+// "" when there was nothing to compare with
+func (c summaryChange) PercentString() string {
+    if c.Amount == 0 {
+        return ""
+    }
+    s := models.GetPreferences().Formatter().Percent(c.Change/math.Abs(c.Amount)*100, 0)
+    if !strings.HasPrefix(s, "-") {
+        s = "+" + s
+    }
+    return s
+}
+
+// Class is change-good or change-bad depending on the direction of the
+// change, or "" when nothing changed
+func (c summaryChange) Class() string {
+    switch {
+    case c.Change == 0:
+        return ""
+    case (c.Change > 0) == c.HigherIsBetter:
+        return "change-good"
+    default:
+        return "change-bad"
+    }
+}
+
 // DashboardHandler displays the dashboard page with summary information
 func DashboardHandler(w http.ResponseWriter, r *http.Request) {
-    // Get current month's date range
-    now := time.Now()
-    startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-    endOfMonth := time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
+    v := validator.NewValidator()
+    today := models.Today()
+    
+    // Get the chosen period, falling back to the current budget period
+    period, err := dashboardPeriod(r, today)
+    var verr *models.ValidationError
+    if errors.As(err, &verr) {
+        v.AddError(verr.Field, verr.Message)
+        period, _ = models.NewPeriod("budget", today)
+    }
     
-    // Get transactions for current month
+    // Get transactions for the period
     filter := models.TransactionFilter{
-        StartDate: startOfMonth,
-        EndDate:   endOfMonth,
+        StartDate: period.Start,
+        EndDate:   period.End,
     }
     
     transactions, err := models.GetTransactions(filter)
     if err != nil {
-        http.Error(w, "Error fetching transactions: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
-    // Calculate summary for current month
-    summary, err := models.GetSummary(startOfMonth, endOfMonth)
+    // Calculate the summary for the period and the two it is compared with
+    summary, err := models.GetSummary(period.Start, period.End)
     if err != nil {
-        http.Error(w, "Error calculating summary: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
+    previous := period.Previous()
+    previousSummary, err := models.GetSummary(previous.Start, previous.End)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    yearAgo := period.YearAgo()
+    yearAgoSummary, err := models.GetSummary(yearAgo.Start, yearAgo.End)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    cards := []summaryCard{
+        {Title: "Total Income", Class: "card-income"},
+        {Title: "Total Expenses", Class: "card-expense"},
+        {Title: "Balance", Class: "card-balance"},
+    }
+    for i, key := range []string{"totalIncome", "totalExpense", "balance"} {
+        higherIsBetter := key != "totalExpense"
+        cards[i].Amount = summary[key]
+        cards[i].Previous = summaryChange{Amount: previousSummary[key], Change: summary[key] - previousSummary[key], HigherIsBetter: higherIsBetter}
+        cards[i].YearAgo = summaryChange{Amount: yearAgoSummary[key], Change: summary[key] - yearAgoSummary[key], HigherIsBetter: higherIsBetter}
+    }
+    
     // Get recent transactions (limited to 5)
     recentFilter := models.TransactionFilter{
         SortBy:        "date",
@@ -41,7 +176,7 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
     
     recentTransactions, err := models.GetTransactions(recentFilter)
     if err != nil {
-        http.Error(w, "Error fetching recent transactions: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
@@ -50,17 +185,87 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
         recentTransactions = recentTransactions[:5]
     }
     
-    data := struct {
-        Summary           map[string]float64
-        Transactions      []models.Transaction
-        RecentTransactions []models.Transaction
-        CurrentMonth      string
-    }{
+    // Get savings goals for the goals widget, nearest target first
+    goals, err := models.GetAllGoals()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    if len(goals) > 3 {
+        goals = goals[:3]
+    }
+    
+    // Only offer the next period once it has started
+    nextURL := ""
+    if next := period.Next(); !next.Start.After(today) {
+        nextURL = periodURL(next)
+    }
+    
+    data := dashboardData{
         Summary:           summary,
+        Cards:             cards,
+        Period:            period,
+        PreviousPeriod:    previous,
+        YearAgoPeriod:     yearAgo,
+        PeriodOptions:     periodOptions,
+        PreviousURL:       periodURL(previous),
+        NextURL:           nextURL,
         Transactions:      transactions,
         RecentTransactions: recentTransactions,
-        CurrentMonth:      now.Format("January 2006"),
+        Goals:             goals,
+        Validator:         v,
     }
     
-    render(w, "dashboard.html", data)
-}
\ No newline at end of file
+    status := http.StatusOK
+    if !v.ValidData() {
+        status = http.StatusUnprocessableEntity
+    }
+    renderStatus(w, status, "dashboard.html", data)
+}
+
+// dashboardPeriod reads the period from the query: its kind, and either the
+// date it contains or, for a custom period, its start and end dates. The
+// default is the current budget period.
+func dashboardPeriod(r *http.Request, today time.Time) (models.Period, error) {
+    query := r.URL.Query()
+    
+    kind := query.Get("period")
+    if kind == "" {
+        kind = "budget"
+    }
+    
+    if kind == "custom" {
+        start, err := time.Parse("2006-01-02", query.Get("start"))
+        if err != nil {
+            return models.Period{}, &models.ValidationError{Field: "start", Message: "Please enter a valid start date"}
+        }
+        end, err := time.Parse("2006-01-02", query.Get("end"))
+        if err != nil {
+            return models.Period{}, &models.ValidationError{Field: "end", Message: "Please enter a valid end date"}
+        }
+        return models.NewCustomPeriod(start, end)
+    }
+    
+    date := today
+    if value := query.Get("date"); value != "" {
+        parsed, err := time.Parse("2006-01-02", value)
+        if err != nil {
+            return models.Period{}, &models.ValidationError{Field: "date", Message: "Please enter a valid date"}
+        }
+        date = parsed
+    }
+    return models.NewPeriod(kind, date)
+}
+
+// periodURL links to the dashboard showing period
+func periodURL(period models.Period) string {
+    query := url.Values{}
+    query.Set("period", period.Kind)
+    if period.Kind == "custom" {
+        query.Set("start", period.Start.Format("2006-01-02"))
+        query.Set("end", period.End.Format("2006-01-02"))
+    } else {
+        query.Set("date", period.End.Format("2006-01-02"))
+    }
+    return "/?" + query.Encode()
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+
+    "github.com/bryan/finance-tracker/internal/middleware"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// errorPage is the data passed to the error_*.html templates
+type errorPage struct {
+    Status  int
+    Title   string
+    Message string
+}
+
+// internalErrorPage is shown for any error that has no more specific page
+var internalErrorPage = errorPage{
+    Status:  http.StatusInternalServerError,
+    Title:   "Something went wrong",
+    Message: "An unexpected error occurred. Please try again later.",
+}
+
+// renderError maps an error to an HTTP status and renders the matching error
+// page. Database and driver messages are logged but never sent to the client.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+    page := errorPageFor(r, err)
+    renderStatus(w, page.Status, fmt.Sprintf("error_%d.html", page.Status), page)
+}
+
+// renderJSONError is renderError for JSON endpoints
+func renderJSONError(w http.ResponseWriter, r *http.Request, err error) {
+    page := errorPageFor(r, err)
+    renderJSON(w, page.Status, map[string]string{
+        "error":   page.Title,
+        "message": page.Message,
+    })
+}
+
+// renderJSONValidation reports every invalid field as a 422 JSON response,
+// with the messages keyed by field
+func renderJSONValidation(w http.ResponseWriter, v *validator.Validator) {
+    renderJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+        "error":   "Invalid input",
+        "message": "Some of the values are not valid.",
+        "errors":  v.Errors,
+    })
+}
+
+// errorPageFor maps an error to the status and message shown to the client,
+// logging anything unexpected
+func errorPageFor(r *http.Request, err error) errorPage {
+    page := internalErrorPage
+
+    var vErr *models.ValidationError
+
+    switch {
+    case errors.Is(err, models.ErrNotFound):
+        page.Status = http.StatusNotFound
+        page.Title = "Not found"
+        page.Message = "The record you are looking for does not exist or has been removed."
+    case errors.As(err, &vErr):
+        page.Status = http.StatusUnprocessableEntity
+        page.Title = "Invalid input"
+        page.Message = vErr.Message
+    case errors.Is(err, models.ErrForeignKey):
+        page.Status = http.StatusConflict
+        page.Title = "Change not allowed"
+        page.Message = "This change would break a link to another record, for example a category that is still in use."
+    case errors.Is(err, models.ErrConflict):
+        page.Status = http.StatusConflict
+        page.Title = "Conflict"
+        page.Message = "This change conflicts with an existing record."
+    }
+
+    if page.Status == http.StatusInternalServerError {
+        slog.Error("Internal error",
+            "request_id", middleware.GetRequestID(r.Context()),
+            "method", r.Method,
+            "path", r.URL.Path,
+            "err", err,
+        )
+    }
+
+    return page
+}
+
+// NotFoundHandler renders the 404 page for URLs that match no route
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+    renderError(w, r, models.ErrNotFound)
+}
+
+// ServerErrorHandler renders the 500 page without logging; it is used after
+// the recovery middleware has already logged a panic
+func ServerErrorHandler(w http.ResponseWriter, r *http.Request) {
+    renderStatus(w, http.StatusInternalServerError, "error_500.html", internalErrorPage)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// recentRateLimit is how many rates the exchange rates page lists
+const recentRateLimit = 100
+
+// exchangeRatesData is passed to exchange_rates.html
+type exchangeRatesData struct {
+    Rates        []models.ExchangeRate // The most recent ones
+    Total        int
+    Rate         models.ExchangeRate
+    BaseCurrency string
+    Currencies   []locale.Currency
+    Flash        string
+    Validator    *validator.Validator
+}
+
+// ExchangeRatesHandler lists the most recent exchange rates with forms to
+// add one and to import a file from the European Central Bank
+func ExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+    rate := models.ExchangeRate{Base: "EUR", Quote: models.GetPreferences().Currency, Date: models.Today()}
+    if rate.Quote == rate.Base {
+        rate.Quote = "USD"
+    }
+
+    flash := ""
+    if imported, err := strconv.Atoi(r.URL.Query().Get("imported")); err == nil {
+        flash = fmt.Sprintf("%d exchange rate(s) were imported.", imported)
+    }
+    renderExchangeRates(w, r, http.StatusOK, rate, flash, validator.NewValidator())
+}
+
+// SaveExchangeRateHandler records a rate entered by hand, replacing any
+// rate for the same currencies and day
+func SaveExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    rate := models.ExchangeRate{
+        Base:   models.ParseCurrency(r.PostForm.Get("base")),
+        Quote:  models.ParseCurrency(r.PostForm.Get("quote")),
+        Source: "manual",
+    }
+
+    if date, err := time.Parse("2006-01-02", r.PostForm.Get("date")); err == nil {
+        rate.Date = date
+    } else {
+        v.AddError("date", "Date must be a valid date")
+    }
+
+    if value, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("rate")), 64); err == nil {
+        rate.Rate = value
+    } else {
+        v.AddError("rate", "Rate must be a valid number")
+    }
+
+    models.ValidateExchangeRate(v, &rate)
+
+    if !v.ValidData() {
+        renderExchangeRates(w, r, http.StatusUnprocessableEntity, rate, "", v)
+        return
+    }
+
+    if err := rate.Save(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/exchange-rates", http.StatusSeeOther)
+}
+
+// ImportExchangeRatesHandler loads the rates from an uploaded ECB CSV or XML
+// file, such as eurofxref-hist.csv
+func ImportExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+    v := validator.NewValidator()
+    rate := models.ExchangeRate{Base: "EUR", Date: models.Today()}
+
+    // Leave room for the multipart framing around the file
+    r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+64<<10)
+    file, _, err := r.FormFile("file")
+    var tooLarge *http.MaxBytesError
+    switch {
+    case errors.As(err, &tooLarge):
+        v.AddError("file", fmt.Sprintf("Files cannot be larger than %s", models.Attachment{Size: maxUploadSize}.SizeString()))
+    case err != nil:
+        v.AddError("file", "Please choose a file to import")
+    }
+    if !v.ValidData() {
+        renderExchangeRates(w, r, http.StatusUnprocessableEntity, rate, "", v)
+        return
+    }
+    defer file.Close()
+
+    data, err := io.ReadAll(io.LimitReader(file, maxUploadSize))
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    rates, err := models.ParseECBRates(data)
+    var verr *models.ValidationError
+    if errors.As(err, &verr) {
+        v.AddError(verr.Field, verr.Message)
+        renderExchangeRates(w, r, http.StatusUnprocessableEntity, rate, "", v)
+        return
+    }
+
+    imported, err := models.ImportExchangeRates(rates)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/exchange-rates?imported=%d", imported), http.StatusSeeOther)
+}
+
+// DeleteExchangeRateHandler removes an exchange rate
+func DeleteExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    rate := &models.ExchangeRate{ID: id}
+    if err := rate.Delete(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/exchange-rates", http.StatusSeeOther)
+}
+
+func renderExchangeRates(w http.ResponseWriter, r *http.Request, status int, rate models.ExchangeRate, flash string, v *validator.Validator) {
+    rates, err := models.GetExchangeRates(recentRateLimit)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    total, err := models.CountExchangeRates()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderStatus(w, status, "exchange_rates.html", exchangeRatesData{
+        Rates:        rates,
+        Total:        total,
+        Rate:         rate,
+        BaseCurrency: models.GetPreferences().Currency,
+        Currencies:   locale.Currencies,
+        Flash:        flash,
+        Validator:    v,
+    })
+}
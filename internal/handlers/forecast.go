@@ -0,0 +1,157 @@
+package handlers
+
+import (
+    "errors"
+    "html/template"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/charts"
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// forecastData is passed to forecast.html
+type forecastData struct {
+    Accounts  []models.Account // Asset accounts that can be forecast
+    AccountID int
+    Days      int
+    Threshold float64
+    Forecast  *models.Forecast
+    Risks     []dateRange
+    Chart     template.HTML
+    Validator *validator.Validator
+}
+
+// dateRange is a run of consecutive days
+type dateRange struct {
+    Start, End time.Time
+}
+
+// ForecastHandler projects an account's balance over the coming days and
+// shows when it is expected to fall below a threshold
+func ForecastHandler(w http.ResponseWriter, r *http.Request) {
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    data := forecastData{
+        Days:      models.DefaultForecastDays,
+        Validator: validator.NewValidator(),
+    }
+    for _, account := range accounts {
+        if account.IsLiability() {
+            continue
+        }
+        data.Accounts = append(data.Accounts, account)
+        // Default to the first checking account
+        if data.AccountID == 0 && account.Type == "checking" {
+            data.AccountID = account.ID
+        }
+    }
+    if data.AccountID == 0 && len(data.Accounts) > 0 {
+        data.AccountID = data.Accounts[0].ID
+    }
+
+    query := r.URL.Query()
+    v := data.Validator
+    if value := query.Get("account_id"); value != "" {
+        id, err := strconv.Atoi(value)
+        if err != nil || id <= 0 {
+            v.AddError("account_id", "Please select a valid account")
+        }
+        data.AccountID = id
+    }
+    if value := strings.TrimSpace(query.Get("days")); value != "" {
+        days, err := strconv.Atoi(value)
+        if err != nil {
+            v.AddError("days", "Days must be a whole number")
+        }
+        data.Days = days
+    }
+    if value := strings.TrimSpace(query.Get("threshold")); value != "" {
+        threshold, err := strconv.ParseFloat(value, 64)
+        if err != nil {
+            v.AddError("threshold", "Threshold must be a valid number")
+        }
+        data.Threshold = threshold
+    }
+    v.Check(data.Days >= 1 && data.Days <= models.MaxForecastDays, "days", "Forecasts can cover 1 to 365 days")
+
+    if !v.ValidData() || data.AccountID == 0 {
+        status := http.StatusOK
+        if !v.ValidData() {
+            status = http.StatusUnprocessableEntity
+        }
+        renderStatus(w, status, "forecast.html", data)
+        return
+    }
+
+    forecast, err := models.GetForecast(data.AccountID, data.Days, data.Threshold, models.Now())
+    var verr *models.ValidationError
+    if errors.As(err, &verr) {
+        v.AddError(verr.Field, verr.Message)
+        renderStatus(w, http.StatusUnprocessableEntity, "forecast.html", data)
+        return
+    }
+    if errors.Is(err, models.ErrNotFound) {
+        v.AddError("account_id", "The selected account no longer exists")
+        renderStatus(w, http.StatusUnprocessableEntity, "forecast.html", data)
+        return
+    }
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    data.Forecast = forecast
+    data.Risks = consecutiveRanges(forecast.RiskDates)
+    data.Chart = forecastChart(forecast).SVG()
+
+    render(w, "forecast.html", data)
+}
+
+// consecutiveRanges groups sorted dates into runs of consecutive days
+func consecutiveRanges(dates []time.Time) []dateRange {
+    var ranges []dateRange
+    for _, date := range dates {
+        if n := len(ranges); n > 0 && ranges[n-1].End.AddDate(0, 0, 1).Equal(date) {
+            ranges[n-1].End = date
+            continue
+        }
+        ranges = append(ranges, dateRange{Start: date, End: date})
+    }
+    return ranges
+}
+
+// forecastChart plots the projected balance against the threshold,
+// starting from today's balance, in the account's currency
+func forecastChart(f *models.Forecast) charts.LineChart {
+    formatter := models.GetPreferences().Formatter()
+    formatter.Currency, _ = locale.LookupCurrency(f.Account.Currency)
+
+    chart := charts.LineChart{
+        Width:  800,
+        Height: 300,
+        Labels: []string{f.StartDate.Format("Jan 2")},
+        Format: formatter.Compact,
+    }
+    balances := []float64{f.StartBalance}
+    thresholds := []float64{f.Threshold}
+    for _, day := range f.Days {
+        chart.Labels = append(chart.Labels, day.Date.Format("Jan 2"))
+        balances = append(balances, day.Balance)
+        thresholds = append(thresholds, f.Threshold)
+    }
+
+    chart.Series = []charts.Series{
+        {Name: "Threshold", Class: "threshold", Values: thresholds},
+        {Name: "Projected balance", Class: "forecast", Values: balances},
+    }
+    return chart
+}
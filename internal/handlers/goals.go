@@ -0,0 +1,310 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// maxContributionCandidates is how many recent transactions the goal page
+// offers to link
+const maxContributionCandidates = 50
+
+// goalsData is passed to goals.html
+type goalsData struct {
+    Goals     []models.Goal
+    Goal      models.Goal
+    Accounts  []models.Account
+    Validator *validator.Validator
+}
+
+// goalData is passed to goal.html
+type goalData struct {
+    Goal          models.Goal
+    Form          models.Goal
+    Accounts      []models.Account
+    Contributions []models.GoalContribution
+    Candidates    []models.Transaction
+    Validator     *validator.Validator
+}
+
+// GoalsHandler lists the savings goals with a form to add another
+func GoalsHandler(w http.ResponseWriter, r *http.Request) {
+    goal := models.Goal{StartDate: models.Today()}
+    renderGoals(w, r, http.StatusOK, goal, validator.NewValidator())
+}
+
+// CreateGoalHandler handles the submission of a new goal
+func CreateGoalHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    goal := parseGoalForm(r, v)
+    models.ValidateGoal(v, &goal)
+
+    if v.ValidData() {
+        err := goal.Create()
+        if err == nil {
+            http.Redirect(w, r, fmt.Sprintf("/goals/%d", goal.ID), http.StatusSeeOther)
+            return
+        }
+        if !addGoalSaveError(v, err) {
+            renderError(w, r, err)
+            return
+        }
+    }
+
+    renderGoals(w, r, http.StatusUnprocessableEntity, goal, v)
+}
+
+// GoalHandler shows a goal's progress and contributions with a form to edit it
+func GoalHandler(w http.ResponseWriter, r *http.Request) {
+    goal, ok := goalFromRequest(w, r)
+    if !ok {
+        return
+    }
+    renderGoal(w, r, http.StatusOK, goal, goal, validator.NewValidator())
+}
+
+// UpdateGoalHandler handles the submission of an edited goal
+func UpdateGoalHandler(w http.ResponseWriter, r *http.Request) {
+    goal, ok := goalFromRequest(w, r)
+    if !ok {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    form := parseGoalForm(r, v)
+    form.ID = goal.ID
+    models.ValidateGoal(v, &form)
+
+    if v.ValidData() {
+        err := form.Update()
+        if err == nil {
+            http.Redirect(w, r, fmt.Sprintf("/goals/%d", goal.ID), http.StatusSeeOther)
+            return
+        }
+        if !addGoalSaveError(v, err) {
+            renderError(w, r, err)
+            return
+        }
+    }
+
+    renderGoal(w, r, http.StatusUnprocessableEntity, goal, form, v)
+}
+
+// DeleteGoalHandler removes a goal
+func DeleteGoalHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    goal := &models.Goal{ID: id}
+    if err := goal.Delete(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/goals", http.StatusSeeOther)
+}
+
+// LinkContributionHandler counts a transaction toward a goal
+func LinkContributionHandler(w http.ResponseWriter, r *http.Request) {
+    goal, ok := goalFromRequest(w, r)
+    if !ok {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    transactionID, err := strconv.Atoi(r.PostForm.Get("transaction_id"))
+    if err != nil || transactionID <= 0 {
+        v.AddError("transaction_id", "Please select a transaction")
+    } else if err := models.LinkContribution(goal.ID, transactionID); errors.Is(err, models.ErrNotFound) {
+        v.AddError("transaction_id", "The selected transaction no longer exists")
+    } else if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    if !v.ValidData() {
+        renderGoal(w, r, http.StatusUnprocessableEntity, goal, goal, v)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/goals/%d", goal.ID), http.StatusSeeOther)
+}
+
+// UnlinkContributionHandler stops counting a transaction toward a goal
+func UnlinkContributionHandler(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    goalID, err := strconv.Atoi(vars["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+    transactionID, err := strconv.Atoi(vars["transaction"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    if err := models.UnlinkContribution(goalID, transactionID); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/goals/%d", goalID), http.StatusSeeOther)
+}
+
+// parseGoalForm reads a goal from the submitted form. Values that cannot be
+// parsed are reported on v.
+func parseGoalForm(r *http.Request, v *validator.Validator) models.Goal {
+    goal := models.Goal{
+        Name: strings.TrimSpace(r.PostForm.Get("name")),
+    }
+
+    if amount, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("target_amount")), 64); err == nil {
+        goal.TargetAmount = amount
+    } else {
+        v.AddError("target_amount", "Target amount must be a valid number")
+    }
+
+    if date, err := time.Parse("2006-01-02", r.PostForm.Get("start_date")); err == nil {
+        goal.StartDate = date
+    } else {
+        v.AddError("start_date", "Start date must be a valid date")
+    }
+
+    if value := r.PostForm.Get("target_date"); value != "" {
+        if date, err := time.Parse("2006-01-02", value); err == nil {
+            goal.TargetDate = &date
+        } else {
+            v.AddError("target_date", "Target date must be a valid date")
+        }
+    }
+
+    if value := r.PostForm.Get("account_id"); value != "" {
+        id, err := strconv.Atoi(value)
+        if err != nil || id <= 0 {
+            v.AddError("account_id", "Please select a valid account")
+        }
+        goal.AccountID = id
+    }
+
+    return goal
+}
+
+// addGoalSaveError turns the save errors a user can fix into form errors
+func addGoalSaveError(v *validator.Validator, err error) bool {
+    switch {
+    case errors.Is(err, models.ErrConflict):
+        v.AddError("name", "A goal with this name already exists")
+    case errors.Is(err, models.ErrForeignKey):
+        v.AddError("account_id", "The selected account no longer exists")
+    default:
+        return false
+    }
+    return true
+}
+
+// goalFromRequest loads the goal named by the {id} route variable,
+// rendering the error page if it cannot
+func goalFromRequest(w http.ResponseWriter, r *http.Request) (models.Goal, bool) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return models.Goal{}, false
+    }
+
+    goal, err := models.GetGoalByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return models.Goal{}, false
+    }
+    return goal, true
+}
+
+func renderGoals(w http.ResponseWriter, r *http.Request, status int, goal models.Goal, v *validator.Validator) {
+    goals, err := models.GetAllGoals()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderStatus(w, status, "goals.html", goalsData{
+        Goals:     goals,
+        Goal:      goal,
+        Accounts:  accounts,
+        Validator: v,
+    })
+}
+
+func renderGoal(w http.ResponseWriter, r *http.Request, status int, goal, form models.Goal, v *validator.Validator) {
+    contributions, err := models.GetGoalContributions(goal)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    // Offer recent transactions that do not count toward the goal yet
+    recent, err := models.GetTransactions(models.TransactionFilter{SortBy: "date", SortDirection: "DESC"})
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    counted := make(map[int]bool, len(contributions))
+    for _, c := range contributions {
+        counted[c.ID] = true
+    }
+    var candidates []models.Transaction
+    for _, transaction := range recent {
+        if len(candidates) == maxContributionCandidates {
+            break
+        }
+        if !counted[transaction.ID] {
+            candidates = append(candidates, transaction)
+        }
+    }
+
+    renderStatus(w, status, "goal.html", goalData{
+        Goal:          goal,
+        Form:          form,
+        Accounts:      accounts,
+        Contributions: contributions,
+        Candidates:    candidates,
+        Validator:     v,
+    })
+}
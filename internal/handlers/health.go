@@ -0,0 +1,143 @@
+package handlers
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// requiredTemplates must be cached for the server to be considered ready
+var requiredTemplates = []string{
+    "dashboard.html",
+    "transaction_list.html",
+    "transaction_form.html",
+    "transaction_edit.html",
+    "error_500.html",
+}
+
+var (
+    readyTimeout      = 2 * time.Second
+    expectedMigration uint
+)
+
+// ConfigureReadiness sets the timeout for the database checks and the schema
+// version the server was started against
+func ConfigureReadiness(timeout time.Duration, expected uint) {
+    readyTimeout = timeout
+    expectedMigration = expected
+}
+
+// checkResult is the outcome of a single readiness check
+type checkResult struct {
+    Status    string  `json:"status"`
+    LatencyMS float64 `json:"latency_ms"`
+    Error     string  `json:"error,omitempty"`
+    Version   *uint   `json:"version,omitempty"`
+}
+
+// HealthzHandler reports that the process is up and serving requests
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+    renderJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ReadyzHandler reports whether the server can handle traffic: the database
+// answers, the schema is at the expected version and the templates loaded
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+    // Both database checks share one deadline so a stuck database cannot
+    // hold the probe past the ready timeout
+    ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+    defer cancel()
+
+    checks := map[string]checkResult{
+        "database":   checkDatabase(ctx),
+        "migrations": checkMigrations(ctx),
+        "templates":  checkTemplates(),
+    }
+
+    status := http.StatusOK
+    overall := "ok"
+    for name, check := range checks {
+        if check.Status != "ok" {
+            status = http.StatusServiceUnavailable
+            overall = "unavailable"
+            slog.Warn("Readiness check failed", "check", name, "error", check.Error)
+        }
+    }
+
+    renderJSON(w, status, struct {
+        Status string                 `json:"status"`
+        Checks map[string]checkResult `json:"checks"`
+    }{
+        Status: overall,
+        Checks: checks,
+    })
+}
+
+func checkDatabase(ctx context.Context) checkResult {
+    start := time.Now()
+
+    if database.DB == nil {
+        return failed(start, "not connected")
+    }
+    if err := database.DB.PingContext(ctx); err != nil {
+        slog.Debug("Database ping failed", "err", err)
+        return failed(start, "ping failed")
+    }
+    return passed(start)
+}
+
+// checkMigrations reads the version golang-migrate recorded through the
+// shared pool, so it recovers along with it after the database restarts
+func checkMigrations(ctx context.Context) checkResult {
+    start := time.Now()
+
+    if database.DB == nil {
+        return failed(start, "not connected")
+    }
+
+    var version int64
+    var dirty bool
+    err := database.DB.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+    if err != nil {
+        slog.Debug("Reading migration version failed", "err", err)
+        return failed(start, "could not read schema version")
+    }
+
+    result := passed(start)
+    v := uint(version)
+    result.Version = &v
+
+    switch {
+    case dirty:
+        result.Status = "fail"
+        result.Error = "schema is dirty"
+    case v != expectedMigration:
+        result.Status = "fail"
+        result.Error = "unexpected schema version"
+    }
+    return result
+}
+
+func checkTemplates() checkResult {
+    start := time.Now()
+
+    if !TemplatesLoaded(requiredTemplates...) {
+        return failed(start, "templates not loaded")
+    }
+    return passed(start)
+}
+
+func passed(start time.Time) checkResult {
+    return checkResult{Status: "ok", LatencyMS: millisecondsSince(start)}
+}
+
+func failed(start time.Time, reason string) checkResult {
+    return checkResult{Status: "fail", LatencyMS: millisecondsSince(start), Error: reason}
+}
+
+func millisecondsSince(start time.Time) float64 {
+    return float64(time.Since(start).Microseconds()) / 1000
+}
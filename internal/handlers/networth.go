@@ -0,0 +1,200 @@
+package handlers
+
+import (
+    "errors"
+    "html/template"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/charts"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// maxChartPoints keeps long ranges readable by sampling the daily values
+const maxChartPoints = 370
+
+// netWorthData is passed to networth.html
+type netWorthData struct {
+    StartDate time.Time
+    EndDate   time.Time
+    Latest    models.NetWorthPoint
+    Change    float64 // Net worth change over the range
+    Chart     template.HTML
+    Balances  []models.AccountBalance // Also the accounts offered for snapshots
+    Snapshots []models.BalanceSnapshot
+    Snapshot  models.BalanceSnapshot
+    Validator *validator.Validator
+}
+
+// NetWorthHandler charts assets, liabilities and net worth over a date
+// range, by default the last twelve months
+func NetWorthHandler(w http.ResponseWriter, r *http.Request) {
+    snapshot := models.BalanceSnapshot{Date: models.Today()}
+    renderNetWorth(w, r, http.StatusOK, snapshot, validator.NewValidator())
+}
+
+// SaveSnapshotHandler records an account balance, replacing any snapshot
+// for the same account and day
+func SaveSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    snapshot := models.BalanceSnapshot{
+        Note: strings.TrimSpace(r.PostForm.Get("note")),
+    }
+
+    if id, err := strconv.Atoi(r.PostForm.Get("account_id")); err == nil && id > 0 {
+        snapshot.AccountID = id
+    } else {
+        v.AddError("account_id", "Please select an account")
+    }
+
+    if date, err := time.Parse("2006-01-02", r.PostForm.Get("date")); err == nil {
+        snapshot.Date = date
+    } else {
+        v.AddError("date", "Date must be a valid date")
+    }
+
+    if balance, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("balance")), 64); err == nil {
+        snapshot.Balance = balance
+    } else {
+        v.AddError("balance", "Balance must be a valid number")
+    }
+
+    models.ValidateBalanceSnapshot(v, &snapshot)
+
+    if v.ValidData() {
+        err := snapshot.Save()
+        if err == nil {
+            http.Redirect(w, r, "/networth", http.StatusSeeOther)
+            return
+        }
+        if !errors.Is(err, models.ErrForeignKey) {
+            renderError(w, r, err)
+            return
+        }
+        v.AddError("account_id", "The selected account no longer exists")
+    }
+
+    renderNetWorth(w, r, http.StatusUnprocessableEntity, snapshot, v)
+}
+
+// DeleteSnapshotHandler removes a balance snapshot
+func DeleteSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    snapshot := &models.BalanceSnapshot{ID: id}
+    if err := snapshot.Delete(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/networth", http.StatusSeeOther)
+}
+
+// netWorthRange reads start_date and end_date from the query. Missing dates
+// fall back to the last twelve months ending today, invalid ones are reported
+// through v, and the range is limited to models.MaxNetWorthDays.
+func netWorthRange(r *http.Request, v *validator.Validator) (time.Time, time.Time) {
+    query := r.URL.Query()
+
+    end := models.Today()
+    if value := query.Get("end_date"); value != "" {
+        if date, err := time.Parse("2006-01-02", value); err == nil {
+            end = date
+        } else {
+            v.AddError("end_date", "End date must be a valid date")
+        }
+    }
+
+    start := end.AddDate(-1, 0, 0)
+    if value := query.Get("start_date"); value != "" {
+        if date, err := time.Parse("2006-01-02", value); err != nil {
+            v.AddError("start_date", "Start date must be a valid date")
+        } else if !date.Before(end) {
+            v.AddError("start_date", "Start date must be before the end date")
+        } else {
+            start = date
+        }
+    }
+
+    if earliest := end.AddDate(0, 0, -models.MaxNetWorthDays+1); start.Before(earliest) {
+        start = earliest
+    }
+    return start, end
+}
+
+// netWorthChart samples the daily points down to maxChartPoints, always
+// keeping the last day
+func netWorthChart(points []models.NetWorthPoint) charts.LineChart {
+    every := (len(points) + maxChartPoints - 1) / maxChartPoints
+    if every < 1 {
+        every = 1
+    }
+
+    chart := charts.LineChart{Width: 800, Height: 300, Format: chartMoney()}
+    var assets, liabilities, netWorth []float64
+    for i, point := range points {
+        if i%every != 0 && i != len(points)-1 {
+            continue
+        }
+        chart.Labels = append(chart.Labels, point.Date.Format("Jan 2, 06"))
+        assets = append(assets, point.Assets)
+        liabilities = append(liabilities, point.Liabilities)
+        netWorth = append(netWorth, point.NetWorth)
+    }
+
+    chart.Series = []charts.Series{
+        {Name: "Assets", Class: "assets", Values: assets},
+        {Name: "Liabilities", Class: "liabilities", Values: liabilities},
+        {Name: "Net worth", Class: "networth", Values: netWorth},
+    }
+    return chart
+}
+
+func renderNetWorth(w http.ResponseWriter, r *http.Request, status int, snapshot models.BalanceSnapshot, v *validator.Validator) {
+    start, end := netWorthRange(r, v)
+    if !v.ValidData() {
+        status = http.StatusUnprocessableEntity
+    }
+
+    points, balances, err := models.GetNetWorth(start, end)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    snapshots, err := models.GetBalanceSnapshots()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    data := netWorthData{
+        StartDate: start,
+        EndDate:   end,
+        Chart:     netWorthChart(points).SVG(),
+        Balances:  balances,
+        Snapshots: snapshots,
+        Snapshot:  snapshot,
+        Validator: v,
+    }
+    if len(points) > 0 {
+        data.Latest = points[len(points)-1]
+        data.Change = data.Latest.NetWorth - points[0].NetWorth
+    }
+
+    renderStatus(w, status, "networth.html", data)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// payeesData is passed to payees.html
+type payeesData struct {
+    Payees    []models.PayeeSummary
+    Payee     models.Payee
+    Aliases   string
+    Validator *validator.Validator
+}
+
+// payeeData is passed to payee.html
+type payeeData struct {
+    Payee        models.Payee
+    Payees       []models.Payee // merge targets
+    Months       []models.PayeeMonth
+    Transactions []models.Transaction
+    Validator    *validator.Validator
+    Flash        string
+}
+
+// PayeesHandler lists the payees with their totals and a form to add another
+func PayeesHandler(w http.ResponseWriter, r *http.Request) {
+    renderPayees(w, r, http.StatusOK, payeesData{Validator: validator.NewValidator()})
+}
+
+// CreatePayeeHandler handles the submission of a new payee
+func CreatePayeeHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    data := payeesData{
+        Payee: models.Payee{
+            Name:    r.PostForm.Get("name"),
+            Aliases: models.ParseAliases(r.PostForm.Get("aliases")),
+        },
+        Aliases:   r.PostForm.Get("aliases"),
+        Validator: validator.NewValidator(),
+    }
+
+    models.ValidatePayee(data.Validator, &data.Payee)
+
+    if data.Validator.ValidData() {
+        linked, err := data.Payee.Create(actorFromRequest(r))
+        if err == nil {
+            http.Redirect(w, r, fmt.Sprintf("/payees/%d?linked=%d", data.Payee.ID, linked), http.StatusSeeOther)
+            return
+        }
+        if !errors.Is(err, models.ErrConflict) {
+            renderError(w, r, err)
+            return
+        }
+        data.Validator.AddError("name", "A payee with this name already exists")
+    }
+
+    renderPayees(w, r, http.StatusUnprocessableEntity, data)
+}
+
+// PayeeHandler shows a payee's aliases, monthly totals and transactions
+func PayeeHandler(w http.ResponseWriter, r *http.Request) {
+    payee, ok := payeeFromRequest(w, r)
+    if !ok {
+        return
+    }
+
+    flash := ""
+    if linked, err := strconv.Atoi(r.URL.Query().Get("linked")); err == nil && linked > 0 {
+        flash = fmt.Sprintf("%d existing transaction(s) were linked to this payee.", linked)
+    }
+    if moved, err := strconv.Atoi(r.URL.Query().Get("merged")); err == nil {
+        flash = fmt.Sprintf("Payees merged; %d transaction(s) moved.", moved)
+    }
+
+    renderPayee(w, r, http.StatusOK, payee, validator.NewValidator(), flash)
+}
+
+// AddPayeeAliasHandler adds an alias to a payee
+func AddPayeeAliasHandler(w http.ResponseWriter, r *http.Request) {
+    payee, ok := payeeFromRequest(w, r)
+    if !ok {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    alias := models.NormalizePayee(r.PostForm.Get("alias"))
+    v.Check(alias != "", "alias", "An alias must contain letters")
+    v.Check(validator.MaxLength(alias, 200), "alias", "Aliases cannot exceed 200 characters")
+
+    if v.ValidData() {
+        linked, err := payee.AddAlias(alias, actorFromRequest(r))
+        if err == nil {
+            http.Redirect(w, r, fmt.Sprintf("/payees/%d?linked=%d", payee.ID, linked), http.StatusSeeOther)
+            return
+        }
+        if !errors.Is(err, models.ErrConflict) {
+            renderError(w, r, err)
+            return
+        }
+        v.AddError("alias", fmt.Sprintf("The alias %q is already in use", alias))
+    }
+
+    renderPayee(w, r, http.StatusUnprocessableEntity, payee, v, "")
+}
+
+// DeletePayeeAliasHandler removes an alias from a payee
+func DeletePayeeAliasHandler(w http.ResponseWriter, r *http.Request) {
+    payee, ok := payeeFromRequest(w, r)
+    if !ok {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    if err := payee.RemoveAlias(r.PostForm.Get("alias")); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/payees/%d", payee.ID), http.StatusSeeOther)
+}
+
+// MergePayeeHandler merges a payee into another one
+func MergePayeeHandler(w http.ResponseWriter, r *http.Request) {
+    payee, ok := payeeFromRequest(w, r)
+    if !ok {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    targetID, err := strconv.Atoi(r.PostForm.Get("target_id"))
+    if err != nil || targetID <= 0 {
+        v.AddError("target_id", "Please select the payee to merge into")
+        renderPayee(w, r, http.StatusUnprocessableEntity, payee, v, "")
+        return
+    }
+
+    moved, err := models.MergePayees(payee.ID, targetID, actorFromRequest(r))
+    var verr *models.ValidationError
+    if errors.As(err, &verr) {
+        v.AddError(verr.Field, verr.Message)
+        renderPayee(w, r, http.StatusUnprocessableEntity, payee, v, "")
+        return
+    }
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, fmt.Sprintf("/payees/%d?merged=%d", targetID, moved), http.StatusSeeOther)
+}
+
+// payeeFromRequest loads the payee named by the {id} route variable,
+// rendering the error page if it cannot
+func payeeFromRequest(w http.ResponseWriter, r *http.Request) (models.Payee, bool) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return models.Payee{}, false
+    }
+
+    payee, err := models.GetPayeeByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return models.Payee{}, false
+    }
+    return payee, true
+}
+
+func renderPayees(w http.ResponseWriter, r *http.Request, status int, data payeesData) {
+    payees, err := models.GetPayeeSummaries()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    data.Payees = payees
+
+    renderStatus(w, status, "payees.html", data)
+}
+
+func renderPayee(w http.ResponseWriter, r *http.Request, status int, payee models.Payee, v *validator.Validator, flash string) {
+    months, err := models.GetPayeeMonthlyTotals(payee.ID)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    transactions, err := models.GetTransactions(models.TransactionFilter{PayeeID: payee.ID})
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    all, err := models.GetAllPayees()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    var targets []models.Payee
+    for _, other := range all {
+        if other.ID != payee.ID {
+            targets = append(targets, other)
+        }
+    }
+
+    renderStatus(w, status, "payee.html", payeeData{
+        Payee:        payee,
+        Payees:       targets,
+        Months:       months,
+        Transactions: transactions,
+        Validator:    v,
+        Flash:        flash,
+    })
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+    
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// commonTimeZones are suggested in the time zone field; any IANA name is
+// accepted
+var commonTimeZones = []string{
+    "UTC",
+    "America/New_York",
+    "America/Chicago",
+    "America/Denver",
+    "America/Los_Angeles",
+    "America/Toronto",
+    "Europe/London",
+    "Europe/Paris",
+    "Europe/Berlin",
+    "Europe/Madrid",
+    "Asia/Tokyo",
+    "Asia/Kolkata",
+    "Australia/Sydney",
+    "Pacific/Auckland",
+}
+
+// preferencesData is passed to preferences.html
+type preferencesData struct {
+    Preferences models.Preferences
+    TimeZones   []string
+    Locales     []locale.Locale
+    Currencies  []locale.Currency
+    PayCycles   []string
+    Example     string // An amount and date written with the saved preferences
+    Validator   *validator.Validator
+}
+
+// PreferencesHandler displays the time zone, locale, currency and pay cycle
+// form
+func PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+    renderPreferences(w, http.StatusOK, models.GetPreferences(), validator.NewValidator())
+}
+
+// SavePreferencesHandler updates the preferences
+func SavePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+    
+    prefs := models.Preferences{
+        TimeZone: strings.TrimSpace(r.PostForm.Get("time_zone")),
+        Locale:   r.PostForm.Get("locale"),
+        Currency: r.PostForm.Get("currency"),
+        PayCycle: r.PostForm.Get("pay_cycle"),
+    }
+    
+    v := validator.NewValidator()
+    
+    // The start day only matters for monthly periods, so it is kept valid
+    // when another cycle is chosen
+    prefs.PeriodStartDay = 1
+    if day, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get("period_start_day"))); err == nil {
+        prefs.PeriodStartDay = day
+    } else if prefs.PayCycle == "monthly" {
+        v.AddError("period_start_day", "Please enter a day between 1 and 31")
+    }
+    if prefs.PayCycle != "monthly" && (prefs.PeriodStartDay < 1 || prefs.PeriodStartDay > 31) {
+        prefs.PeriodStartDay = 1
+    }
+    
+    if value := r.PostForm.Get("pay_date"); value != "" {
+        date, err := time.Parse("2006-01-02", value)
+        if err != nil {
+            v.AddError("pay_date", "Please enter a valid date")
+        } else {
+            prefs.PayDate = &date
+        }
+    }
+    
+    models.ValidatePreferences(v, &prefs)
+    if !v.ValidData() {
+        renderPreferences(w, http.StatusUnprocessableEntity, prefs, v)
+        return
+    }
+    
+    if err := prefs.Save(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    http.Redirect(w, r, "/preferences", http.StatusSeeOther)
+}
+
+func renderPreferences(w http.ResponseWriter, status int, prefs models.Preferences, v *validator.Validator) {
+    f := models.GetPreferences().Formatter()
+    renderStatus(w, status, "preferences.html", preferencesData{
+        Preferences: prefs,
+        TimeZones:   commonTimeZones,
+        Locales:     locale.Locales,
+        Currencies:  locale.Currencies,
+        PayCycles:   models.PayCycles,
+        Example:     f.Money(-1234.5) + " · " + f.Date(models.Today()),
+        Validator:   v,
+    })
+}
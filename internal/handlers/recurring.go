@@ -0,0 +1,194 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// recurringFormData is passed to recurring_form.html
+type recurringFormData struct {
+    Item        models.RecurringItem
+    Categories  []models.Category
+    Accounts    []models.Account
+    Frequencies []string
+    Validator   *validator.Validator
+}
+
+// RecurringItemsHandler lists the scheduled income and bills
+func RecurringItemsHandler(w http.ResponseWriter, r *http.Request) {
+    items, err := models.GetAllRecurringItems()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    render(w, "recurring.html", struct {
+        Items []models.RecurringItem
+        Today time.Time
+    }{
+        Items: items,
+        Today: models.Today(),
+    })
+}
+
+// NewRecurringItemHandler displays the form to add a recurring item
+func NewRecurringItemHandler(w http.ResponseWriter, r *http.Request) {
+    item := models.RecurringItem{
+        Frequency: "monthly",
+        StartDate: models.Today(),
+        Enabled:   true,
+    }
+    if id, err := strconv.Atoi(r.URL.Query().Get("account_id")); err == nil {
+        item.AccountID = id
+    }
+    renderRecurringForm(w, r, http.StatusOK, item, validator.NewValidator())
+}
+
+// EditRecurringItemHandler displays the form to edit a recurring item
+func EditRecurringItemHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    item, err := models.GetRecurringItemByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderRecurringForm(w, r, http.StatusOK, item, validator.NewValidator())
+}
+
+// SaveRecurringItemHandler creates or updates a recurring item
+func SaveRecurringItemHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    item := parseRecurringForm(r, v)
+
+    if idValue, ok := mux.Vars(r)["id"]; ok {
+        id, err := strconv.Atoi(idValue)
+        if err != nil {
+            renderError(w, r, models.ErrNotFound)
+            return
+        }
+        item.ID = id
+    }
+
+    models.ValidateRecurringItem(v, &item)
+    if !v.ValidData() {
+        renderRecurringForm(w, r, http.StatusUnprocessableEntity, item, v)
+        return
+    }
+
+    var err error
+    if item.ID > 0 {
+        err = item.Update()
+    } else {
+        err = item.Create()
+    }
+
+    // The category or account may have been removed since the form was loaded
+    if errors.Is(err, models.ErrForeignKey) {
+        v.AddError("category_id", "The selected category or account no longer exists")
+        renderRecurringForm(w, r, http.StatusUnprocessableEntity, item, v)
+        return
+    }
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/recurring", http.StatusSeeOther)
+}
+
+// DeleteRecurringItemHandler removes a recurring item
+func DeleteRecurringItemHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    item := &models.RecurringItem{ID: id}
+    if err := item.Delete(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/recurring", http.StatusSeeOther)
+}
+
+// parseRecurringForm reads a recurring item from the submitted form. Values
+// that cannot be parsed are reported on v.
+func parseRecurringForm(r *http.Request, v *validator.Validator) models.RecurringItem {
+    item := models.RecurringItem{
+        Name:      strings.TrimSpace(r.PostForm.Get("name")),
+        Frequency: r.PostForm.Get("frequency"),
+        Enabled:   r.PostForm.Get("enabled") != "",
+    }
+
+    if amount, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("amount")), 64); err == nil {
+        item.Amount = amount
+    } else {
+        v.AddError("amount", "Amount must be a valid number")
+    }
+
+    if id, err := strconv.Atoi(r.PostForm.Get("category_id")); err == nil {
+        item.CategoryID = id
+    }
+    if id, err := strconv.Atoi(r.PostForm.Get("account_id")); err == nil {
+        item.AccountID = id
+    }
+
+    if date, err := time.Parse("2006-01-02", r.PostForm.Get("start_date")); err == nil {
+        item.StartDate = date
+    } else {
+        v.AddError("start_date", "First date must be a valid date")
+    }
+
+    if value := r.PostForm.Get("end_date"); value != "" {
+        if date, err := time.Parse("2006-01-02", value); err == nil {
+            item.EndDate = &date
+        } else {
+            v.AddError("end_date", "End date must be a valid date")
+        }
+    }
+
+    return item
+}
+
+func renderRecurringForm(w http.ResponseWriter, r *http.Request, status int, item models.RecurringItem, v *validator.Validator) {
+    categories, err := models.GetAllCategories()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderStatus(w, status, "recurring_form.html", recurringFormData{
+        Item:        item,
+        Categories:  categories,
+        Accounts:    accounts,
+        Frequencies: models.RecurringFrequencies,
+        Validator:   v,
+    })
+}
@@ -1,22 +1,76 @@
 package handlers
 
 import (
+    "bytes"
+    "encoding/json"
     "html/template"
     "net/http"
     "path/filepath"
-    "log"
+    "log/slog"
     "fmt"
+    "sync"
+    "time"
+    
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/metrics"
+    "github.com/bryan/finance-tracker/internal/models"
 )
 
-var templateCache = make(map[string]*template.Template)
+var (
+    templateMu    sync.RWMutex
+    templateCache = make(map[string]*template.Template)
+)
+
+// templateFuncs format numbers, amounts and dates with the locale and
+// currency from the current preferences
+var templateFuncs = template.FuncMap{
+    "money": func(v float64) string {
+        return models.GetPreferences().Formatter().Money(v)
+    },
+    "moneyIn": func(v float64, code string) string {
+        c, _ := locale.LookupCurrency(code)
+        return models.GetPreferences().Formatter().MoneyIn(v, c)
+    },
+    "number": func(v float64, decimals int) string {
+        return models.GetPreferences().Formatter().Number(v, decimals)
+    },
+    "percent": func(v float64, decimals int) string {
+        return models.GetPreferences().Formatter().Percent(v, decimals)
+    },
+    "date": func(t time.Time) string {
+        return models.GetPreferences().Formatter().Date(t)
+    },
+    "month": func(t time.Time) string {
+        return models.GetPreferences().Formatter().Month(t)
+    },
+}
+
+// chartMoney formats chart labels as compact amounts in the preferred
+// currency
+func chartMoney() func(float64) string {
+    return models.GetPreferences().Formatter().Compact
+}
+
+// chartPercent formats chart values as whole percentages in the preferred
+// locale
+func chartPercent() func(float64) string {
+    f := models.GetPreferences().Formatter()
+    return func(v float64) string {
+        return f.Percent(v, 0)
+    }
+}
+
+// parsePage parses a page template together with the base layout
+func parsePage(baseLayout, page string) (*template.Template, error) {
+    return template.New(filepath.Base(baseLayout)).Funcs(templateFuncs).ParseFiles(baseLayout, page)
+}
 
-// InitTemplates pre-loads and caches all templates
 // InitTemplates pre-loads and caches all templates
 func InitTemplates() error {
     // Define template paths
     baseLayout := filepath.Join("templates", "layout", "base.html")
     
-    log.Printf("Loading base template from: %s", baseLayout)
+    slog.Debug("Loading base template", "path", baseLayout)
     
     // Get all regular templates
     pages, err := filepath.Glob(filepath.Join("templates", "*.html"))
@@ -24,66 +78,104 @@ func InitTemplates() error {
         return fmt.Errorf("template glob error: %v", err)
     }
     
-    log.Printf("Found %d page templates: %v", len(pages), pages)
+    slog.Debug("Found page templates", "count", len(pages))
     
     // Parse all templates with the base layout
     for _, page := range pages {
         name := filepath.Base(page)
         
-        log.Printf("Parsing template: %s", name)
-        
         // Parse base layout first, then the page
-        tmpl, err := template.ParseFiles(baseLayout, page)
+        tmpl, err := parsePage(baseLayout, page)
         if err != nil {
             return fmt.Errorf("error parsing template %s: %v", name, err)
         }
         
-        // Log defined templates
-        log.Printf("Template %s contains definitions:", name)
-        for _, t := range tmpl.Templates() {
-            log.Printf("  - %s", t.Name())
-        }
-        
+        templateMu.Lock()
         templateCache[name] = tmpl
-        log.Printf("Cached template: %s", name)
+        templateMu.Unlock()
+        slog.Debug("Cached template", "name", name)
     }
     
     return nil
 }
 
-// render executes a template with provided data
+// TemplatesLoaded reports whether every given page template is in the cache
+func TemplatesLoaded(names ...string) bool {
+    templateMu.RLock()
+    defer templateMu.RUnlock()
+    
+    if len(templateCache) == 0 {
+        return false
+    }
+    for _, name := range names {
+        if _, ok := templateCache[name]; !ok {
+            return false
+        }
+    }
+    return true
+}
+
 // render executes a template with provided data
 func render(w http.ResponseWriter, tmpl string, data interface{}) {
+    renderStatus(w, http.StatusOK, tmpl, data)
+}
+
+// renderStatus executes a template and writes it with the given status code.
+// The page is rendered into a buffer first so a failing template never sends
+// a half-written page.
+func renderStatus(w http.ResponseWriter, status int, tmpl string, data interface{}) {
     // Get template from cache
+    templateMu.RLock()
     t, ok := templateCache[tmpl]
+    templateMu.RUnlock()
     if !ok {
-        log.Printf("Template not found in cache: %s", tmpl)
+        slog.Warn("Template not found in cache", "name", tmpl)
         
         // Try parsing on the fly as fallback
         baseLayout := filepath.Join("templates", "layout", "base.html")
         page := filepath.Join("templates", tmpl)
         
-        log.Printf("Attempting to parse %s with %s", page, baseLayout)
-        
         var err error
-        t, err = template.ParseFiles(baseLayout, page)
+        t, err = parsePage(baseLayout, page)
         if err != nil {
-            http.Error(w, "Template not found: "+err.Error(), http.StatusInternalServerError)
-            log.Printf("Error parsing template: %v", err)
+            http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+            slog.Error("Error parsing template", "name", tmpl, "err", err)
             return
         }
         
+        templateMu.Lock()
         templateCache[tmpl] = t
-        log.Printf("Parsed template on demand: %s", tmpl)
+        templateMu.Unlock()
+        slog.Debug("Parsed template on demand", "name", tmpl)
     }
     
-    // Log template data for debugging
-    log.Printf("Rendering template %s with data: %+v", tmpl, data)
-    
     // Execute the template
-    err := t.ExecuteTemplate(w, "base", data)
+    var buf bytes.Buffer
+    start := time.Now()
+    err := t.ExecuteTemplate(&buf, "base", data)
+    metrics.TemplateRenderDuration.Observe(time.Since(start).Seconds(), tmpl)
+    if err != nil {
+        http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+        slog.Error("Template execution error", "name", tmpl, "err", err)
+        return
+    }
+    
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(status)
+    buf.WriteTo(w)
+}
+
+// renderJSON writes data as a JSON response with the given status code
+func renderJSON(w http.ResponseWriter, status int, data interface{}) {
+    body, err := json.Marshal(data)
     if err != nil {
-        http.Error(w, "Template execution error: "+err.Error(), http.StatusInternalServerError)
-        log.Printf("Template execution error: %v", err)
+        http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+        slog.Error("JSON encoding error", "err", err)
+        return
     }
-}
\ No newline at end of file
+    
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    w.Write(body)
+    w.Write([]byte("\n"))
+}
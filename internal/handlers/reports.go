@@ -0,0 +1,392 @@
+package handlers
+
+import (
+    "bytes"
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "html/template"
+    "math"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/charts"
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// defaultTrendMonths is how many months the trend report shows by default,
+// ending with the current month
+const defaultTrendMonths = 12
+
+// trendReportData is passed to report_trends.html
+type trendReportData struct {
+    Report       *models.TrendReport
+    AmountChart  template.HTML
+    NetChart     template.HTML
+    SavingsChart template.HTML
+    Validator    *validator.Validator
+}
+
+// TrendReportHandler shows income, expense, net and savings rate per month
+func TrendReportHandler(w http.ResponseWriter, r *http.Request) {
+    v := validator.NewValidator()
+    start, end, err := trendRange(r)
+    var verr *models.ValidationError
+    if errors.As(err, &verr) {
+        v.AddError(verr.Field, verr.Message)
+        start, end = defaultTrendRange()
+    }
+
+    report, err := models.GetMonthlyTrends(start, end)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    status := http.StatusOK
+    if !v.ValidData() {
+        status = http.StatusUnprocessableEntity
+    }
+
+    renderStatus(w, status, "report_trends.html", trendReportData{
+        Report:       report,
+        AmountChart:  trendAmountChart(report).SVG(),
+        NetChart:     trendNetChart(report).SVG(),
+        SavingsChart: trendSavingsChart(report).SVG(),
+        Validator:    v,
+    })
+}
+
+// TrendReportJSONHandler returns the monthly trend report as JSON
+func TrendReportJSONHandler(w http.ResponseWriter, r *http.Request) {
+    start, end, err := trendRange(r)
+    if err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    report, err := models.GetMonthlyTrends(start, end)
+    if err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    renderJSON(w, http.StatusOK, report)
+}
+
+// defaultTrendRange is the last defaultTrendMonths months, this one included
+func defaultTrendRange() (time.Time, time.Time) {
+    now := models.Now()
+    end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+    return end.AddDate(0, 1-defaultTrendMonths, 0), end
+}
+
+// trendRange reads the start and end months (YYYY-MM) from the query.
+// Either may be left out to use the default range.
+func trendRange(r *http.Request) (time.Time, time.Time, error) {
+    start, end := defaultTrendRange()
+    query := r.URL.Query()
+
+    if value := query.Get("end"); value != "" {
+        month, err := time.Parse("2006-01", value)
+        if err != nil {
+            return start, end, &models.ValidationError{Field: "end", Message: "End month must look like 2024-12"}
+        }
+        end = month
+        start = end.AddDate(0, 1-defaultTrendMonths, 0)
+    }
+
+    if value := query.Get("start"); value != "" {
+        month, err := time.Parse("2006-01", value)
+        if err != nil {
+            return start, end, &models.ValidationError{Field: "start", Message: "Start month must look like 2024-01"}
+        }
+        start = month
+    }
+
+    if start.After(end) {
+        return start, end, &models.ValidationError{Field: "start", Message: "Start month cannot be after the end month"}
+    }
+    if start.AddDate(0, models.MaxTrendMonths-1, 0).Before(end) {
+        return start, end, &models.ValidationError{Field: "start", Message: "Reports can cover at most 120 months"}
+    }
+    return start, end, nil
+}
+
+func monthLabels(report *models.TrendReport) []string {
+    labels := make([]string, len(report.Months))
+    for i, m := range report.Months {
+        labels[i] = m.Month.Format("Jan 06")
+    }
+    return labels
+}
+
+// trendAmountChart compares income and expense side by side
+func trendAmountChart(report *models.TrendReport) charts.BarChart {
+    income := make([]float64, len(report.Months))
+    expense := make([]float64, len(report.Months))
+    for i, m := range report.Months {
+        income[i] = m.Income
+        expense[i] = m.Expense
+    }
+
+    return charts.BarChart{
+        Width:  800,
+        Height: 280,
+        Labels: monthLabels(report),
+        Series: []charts.Series{
+            {Name: "Income", Class: "income", Values: income},
+            {Name: "Expense", Class: "expense", Values: expense},
+        },
+        Format: chartMoney(),
+    }
+}
+
+func trendNetChart(report *models.TrendReport) charts.BarChart {
+    net := make([]float64, len(report.Months))
+    for i, m := range report.Months {
+        net[i] = m.Net
+    }
+
+    return charts.BarChart{
+        Width:  800,
+        Height: 220,
+        Labels: monthLabels(report),
+        Series: []charts.Series{{Name: "Net", Class: "net", Values: net}},
+        Format: chartMoney(),
+    }
+}
+
+// trendSavingsChart plots the savings rate; months without income show no
+// bar. Rates below -100% are cut off so one bad month does not flatten the
+// rest.
+func trendSavingsChart(report *models.TrendReport) charts.BarChart {
+    rates := make([]float64, len(report.Months))
+    for i, m := range report.Months {
+        if m.SavingsRate != nil {
+            rates[i] = math.Max(*m.SavingsRate, -100)
+        }
+    }
+
+    return charts.BarChart{
+        Width:  800,
+        Height: 220,
+        Labels: monthLabels(report),
+        Series: []charts.Series{{Name: "Savings rate", Class: "net", Values: rates}},
+        Format: chartPercent(),
+    }
+}
+
+// maxDonutSlices is how many categories the donut shows before grouping the
+// rest as Other
+const maxDonutSlices = 8
+
+// categoryReportData is passed to report_categories.html
+type categoryReportData struct {
+    Breakdown  *models.CategoryBreakdown
+    Rows       []categoryRow
+    Chart      template.HTML
+    Filter     models.TransactionFilter
+    DateRanges []periodOption
+    Accounts   []models.Account
+    Validator  *validator.Validator
+}
+
+// categoryRow is a category's share with a link to its transactions
+type categoryRow struct {
+    models.CategoryShare
+    Class string // Matches the category's donut slice
+    URL   string
+}
+
+// CategoryReportHandler shows how income or expense splits by category for
+// a period, compared with the period before. It reads the same filters as
+// the transaction list and links each category to its transactions.
+func CategoryReportHandler(w http.ResponseWriter, r *http.Request) {
+    v := validator.NewValidator()
+    filter := parseTransactionFilter(r, v)
+
+    breakdown, err := models.GetCategoryBreakdown(filter)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    data := categoryReportData{
+        Breakdown:  breakdown,
+        Filter:     filter,
+        DateRanges: dateRangeOptions,
+        Accounts:   accounts,
+        Validator:  v,
+    }
+    data.Filter.CategoryType = breakdown.Type
+
+    format := chartMoney()
+    chart := charts.DonutChart{Size: 240, Label: format(breakdown.Total), Format: format}
+    var other float64
+    for i, share := range breakdown.Categories {
+        drill := filter
+        drill.CategoryID = share.CategoryID
+        row := categoryRow{CategoryShare: share, URL: transactionsURL(drill)}
+
+        switch {
+        case share.Amount <= 0:
+        case i < maxDonutSlices:
+            row.Class = fmt.Sprintf("slice-%d", i)
+            chart.Slices = append(chart.Slices, charts.Slice{Name: share.CategoryName, Class: row.Class, Value: share.Amount, URL: row.URL})
+        default:
+            row.Class = "slice-other"
+            other += share.Amount
+        }
+        data.Rows = append(data.Rows, row)
+    }
+    if other > 0 {
+        chart.Slices = append(chart.Slices, charts.Slice{Name: "Other", Class: "slice-other", Value: other})
+    }
+    data.Chart = chart.SVG()
+
+    status := http.StatusOK
+    if !v.ValidData() {
+        status = http.StatusUnprocessableEntity
+    }
+    renderStatus(w, status, "report_categories.html", data)
+}
+
+// transactionsURL links to the transaction list showing the transactions
+// that match filter
+func transactionsURL(filter models.TransactionFilter) string {
+    query := url.Values{}
+    if filter.CategoryID > 0 {
+        query.Set("category_id", strconv.Itoa(filter.CategoryID))
+    }
+    if filter.CategoryType != "" {
+        query.Set("type", filter.CategoryType)
+    }
+    if filter.AccountID > 0 {
+        query.Set("account_id", strconv.Itoa(filter.AccountID))
+    }
+    if filter.PayeeID > 0 {
+        query.Set("payee_id", strconv.Itoa(filter.PayeeID))
+    }
+    if filter.Tag != "" {
+        query.Set("tag", filter.Tag)
+    }
+    if !filter.StartDate.IsZero() {
+        query.Set("start_date", filter.StartDate.Format("2006-01-02"))
+    }
+    if !filter.EndDate.IsZero() {
+        query.Set("end_date", filter.EndDate.Format("2006-01-02"))
+    }
+    return "/transactions?" + query.Encode()
+}
+
+// PivotJSONHandler groups the filtered transactions by the requested
+// dimensions and returns the chosen measures for each group, such as
+// /reports/pivot.json?dimensions=month,category&measures=sum,count
+func PivotJSONHandler(w http.ResponseWriter, r *http.Request) {
+    v := validator.NewValidator()
+    q := parsePivotQuery(r, v)
+    if !v.ValidData() {
+        renderJSONValidation(w, v)
+        return
+    }
+
+    pivot, err := models.GetPivot(q)
+    if err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    renderJSON(w, http.StatusOK, pivot)
+}
+
+// PivotCSVHandler is PivotJSONHandler as a CSV download with one column per
+// dimension and measure, ending with a total row
+func PivotCSVHandler(w http.ResponseWriter, r *http.Request) {
+    v := validator.NewValidator()
+    q := parsePivotQuery(r, v)
+    if !v.ValidData() {
+        renderJSONValidation(w, v)
+        return
+    }
+
+    pivot, err := models.GetPivot(q)
+    if err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    var buf bytes.Buffer
+    out := csv.NewWriter(&buf)
+    out.Write(append(append([]string{}, pivot.Dimensions...), pivot.Measures...))
+    for _, row := range pivot.Rows {
+        out.Write(pivotCSVRecord(row.Keys, row.Values))
+    }
+    keys := make([]string, len(pivot.Dimensions))
+    if len(keys) > 0 {
+        keys[0] = "Total"
+    }
+    out.Write(pivotCSVRecord(keys, pivot.Total.Values))
+    out.Flush()
+    if err := out.Error(); err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+    w.Header().Set("Content-Disposition", `attachment; filename="pivot.csv"`)
+    buf.WriteTo(w)
+}
+
+func pivotCSVRecord(keys []string, values []*float64) []string {
+    record := append([]string{}, keys...)
+    for _, value := range values {
+        if value == nil {
+            record = append(record, "")
+            continue
+        }
+        record = append(record, strconv.FormatFloat(*value, 'f', -1, 64))
+    }
+    return record
+}
+
+// parsePivotQuery reads the dimensions and measures, each a comma separated
+// list or repeated, along with the transaction list's filters. Measures
+// default to sum. Unlike the transaction list, dates are unbounded unless a
+// range, start_date or end_date is given. Filters that cannot be parsed are
+// reported on v.
+func parsePivotQuery(r *http.Request, v *validator.Validator) models.PivotQuery {
+    q := models.PivotQuery{
+        Dimensions: queryList(r, "dimensions"),
+        Measures:   queryList(r, "measures"),
+        Filter:     parseFilter(r, v, time.Time{}, time.Time{}),
+    }
+    if len(q.Measures) == 0 {
+        q.Measures = []string{"sum"}
+    }
+    return q
+}
+
+// queryList collects a query parameter given as a comma separated list,
+// repeated, or both
+func queryList(r *http.Request, name string) []string {
+    var list []string
+    for _, value := range r.URL.Query()[name] {
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+                list = append(list, item)
+            }
+        }
+    }
+    return list
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/bryan/finance-tracker/internal/middleware"
+)
+
+// actorFromRequest identifies who made a change for the audit log. There are
+// no user accounts, so the client address stands in for the person.
+func actorFromRequest(r *http.Request) string {
+    return middleware.ClientIP(r)
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// ruleFormData is passed to rule_form.html
+type ruleFormData struct {
+    Rule       models.Rule
+    Categories []models.Category
+    Accounts   []models.Account
+    Validator  *validator.Validator
+    Preview    *models.RulePreview
+}
+
+// RulesHandler lists the categorization rules in the order they run
+func RulesHandler(w http.ResponseWriter, r *http.Request) {
+    rules, err := models.GetAllRules()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    render(w, "rules.html", struct {
+        Rules []models.Rule
+    }{
+        Rules: rules,
+    })
+}
+
+// NewRuleHandler displays the form to add a rule
+func NewRuleHandler(w http.ResponseWriter, r *http.Request) {
+    rule := models.Rule{
+        Priority:         100,
+        Enabled:          true,
+        DescriptionMatch: models.MatchContains,
+    }
+    renderRuleForm(w, r, http.StatusOK, rule, validator.NewValidator(), nil)
+}
+
+// EditRuleHandler displays the form to edit a rule
+func EditRuleHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    rule, err := models.GetRuleByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderRuleForm(w, r, http.StatusOK, rule, validator.NewValidator(), nil)
+}
+
+// SaveRuleHandler creates or updates a rule. When the preview button was
+// used the rule is tested against existing transactions instead of saved.
+func SaveRuleHandler(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    v := validator.NewValidator()
+    rule := parseRuleForm(r, v)
+
+    if idValue, ok := mux.Vars(r)["id"]; ok {
+        id, err := strconv.Atoi(idValue)
+        if err != nil {
+            renderError(w, r, models.ErrNotFound)
+            return
+        }
+        rule.ID = id
+    }
+
+    models.ValidateRule(v, &rule)
+    if !v.ValidData() {
+        renderRuleForm(w, r, http.StatusUnprocessableEntity, rule, v, nil)
+        return
+    }
+
+    if r.PostForm.Get("intent") == "preview" {
+        preview, err := models.PreviewRule(rule)
+        var verr *models.ValidationError
+        if errors.As(err, &verr) {
+            v.AddError(verr.Field, verr.Message)
+            renderRuleForm(w, r, http.StatusUnprocessableEntity, rule, v, nil)
+            return
+        }
+        if err != nil {
+            renderError(w, r, err)
+            return
+        }
+        renderRuleForm(w, r, http.StatusOK, rule, v, preview)
+        return
+    }
+
+    var err error
+    if rule.ID > 0 {
+        err = rule.Update()
+    } else {
+        err = rule.Create()
+    }
+
+    // The category or account may have been removed since the form was loaded
+    if errors.Is(err, models.ErrForeignKey) {
+        v.AddError("actions", "The selected category or account no longer exists")
+        renderRuleForm(w, r, http.StatusUnprocessableEntity, rule, v, nil)
+        return
+    }
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/rules", http.StatusSeeOther)
+}
+
+// DeleteRuleHandler removes a rule
+func DeleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    rule := &models.Rule{ID: id}
+    if err := rule.Delete(); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/rules", http.StatusSeeOther)
+}
+
+// ApplyRuleHandler applies a saved rule to existing transactions and shows
+// what changed
+func ApplyRuleHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    rule, err := models.GetRuleByID(id)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    result, err := models.ApplyRuleRetroactively(id, actorFromRequest(r))
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    status := http.StatusOK
+    if !result.Applied {
+        status = http.StatusUnprocessableEntity
+    }
+
+    renderStatus(w, status, "bulk_result.html", bulkResultData{
+        Result:    result,
+        Validator: validator.NewValidator(),
+        Rule:      &rule,
+    })
+}
+
+// parseRuleForm reads a rule from the submitted form. Values that cannot be
+// parsed are reported on v.
+func parseRuleForm(r *http.Request, v *validator.Validator) models.Rule {
+    rule := models.Rule{
+        Name:               strings.TrimSpace(r.PostForm.Get("name")),
+        Enabled:            r.PostForm.Get("enabled") != "",
+        DescriptionMatch:   r.PostForm.Get("description_match"),
+        DescriptionPattern: strings.TrimSpace(r.PostForm.Get("description_pattern")),
+        AddTags:            models.ParseTags(r.PostForm.Get("add_tags")),
+        SetDescription:     strings.TrimSpace(r.PostForm.Get("set_description")),
+    }
+
+    priority, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get("priority")))
+    if err != nil {
+        v.AddError("priority", "Priority must be a whole number")
+    }
+    rule.Priority = priority
+
+    rule.AmountMin = parseOptionalAmount(r.PostForm.Get("amount_min"), "amount_min", v)
+    rule.AmountMax = parseOptionalAmount(r.PostForm.Get("amount_max"), "amount_max", v)
+
+    if value := r.PostForm.Get("account_id"); value != "" {
+        id, err := strconv.Atoi(value)
+        if err != nil || id <= 0 {
+            v.AddError("account_id", "Please select a valid account")
+        }
+        rule.AccountID = id
+    }
+
+    if value := r.PostForm.Get("set_category_id"); value != "" {
+        id, err := strconv.Atoi(value)
+        if err != nil || id <= 0 {
+            v.AddError("set_category_id", "Please select a valid category")
+        }
+        rule.SetCategoryID = id
+    }
+
+    return rule
+}
+
+func parseOptionalAmount(value, field string, v *validator.Validator) *float64 {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return nil
+    }
+
+    amount, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        v.AddError(field, "Amount must be a valid number")
+        return nil
+    }
+    return &amount
+}
+
+func renderRuleForm(w http.ResponseWriter, r *http.Request, status int, rule models.Rule, v *validator.Validator, preview *models.RulePreview) {
+    categories, err := models.GetAllCategories()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    renderStatus(w, status, "rule_form.html", ruleFormData{
+        Rule:       rule,
+        Categories: categories,
+        Accounts:   accounts,
+        Validator:  v,
+        Preview:    preview,
+    })
+}
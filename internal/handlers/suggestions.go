@@ -0,0 +1,59 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/bryan/finance-tracker/internal/models"
+)
+
+// maxSuggestions is how many ranked categories the endpoint returns
+const maxSuggestions = 3
+
+// SuggestCategoryHandler returns the likely categories for a transaction
+// being entered, as JSON. The suggestion is only set when the best category
+// is confident enough to pre-select.
+func SuggestCategoryHandler(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+
+    transaction := models.Transaction{
+        Description: strings.TrimSpace(query.Get("description")),
+    }
+    if runes := []rune(transaction.Description); len(runes) > 500 {
+        transaction.Description = string(runes[:500])
+    }
+    if amount, err := strconv.ParseFloat(query.Get("amount"), 64); err == nil && amount > 0 {
+        transaction.Amount = amount
+    }
+    if accountID, err := strconv.Atoi(query.Get("account_id")); err == nil && accountID > 0 {
+        transaction.AccountID = accountID
+    }
+
+    response := struct {
+        Suggestion   *models.CategorySuggestion  `json:"suggestion"`
+        Alternatives []models.CategorySuggestion `json:"alternatives"`
+    }{
+        Alternatives: []models.CategorySuggestion{},
+    }
+
+    if transaction.Description == "" {
+        renderJSON(w, http.StatusOK, response)
+        return
+    }
+
+    suggestions, err := models.SuggestCategories(transaction, maxSuggestions)
+    if err != nil {
+        renderJSONError(w, r, err)
+        return
+    }
+
+    if len(suggestions) > 0 && suggestions[0].Confidence >= models.MinSuggestionConfidence {
+        response.Suggestion = &suggestions[0]
+    }
+    if suggestions != nil {
+        response.Alternatives = suggestions
+    }
+
+    renderJSON(w, http.StatusOK, response)
+}
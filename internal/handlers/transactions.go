@@ -1,39 +1,55 @@
 package handlers
 
 import (
+    "errors"
     "net/http"
     "strconv"
+    "strings"
     "time"
     
     "github.com/gorilla/mux"
     
+    "github.com/bryan/finance-tracker/internal/locale"
     "github.com/bryan/finance-tracker/internal/models"
     "github.com/bryan/finance-tracker/internal/validator"
 )
 
+// transactionFormData is passed to the add and edit transaction templates
+type transactionFormData struct {
+    Transaction models.Transaction
+    Categories  []models.Category
+    Accounts    []models.Account
+    Payees      []models.Payee
+    Currencies  []locale.Currency // Suggested codes; any ISO 4217 code is accepted
+    Validator   *validator.Validator
+    History     []models.AuditEntry
+    Attachments []models.Attachment
+}
+
 // ListTransactionsHandler displays a list of all transactions
 func ListTransactionsHandler(w http.ResponseWriter, r *http.Request) {
     // Parse query parameters for filtering
-    filter := parseTransactionFilter(r)
+    v := validator.NewValidator()
+    filter := parseTransactionFilter(r, v)
     
     // Get transactions based on filter
     transactions, err := models.GetTransactions(filter)
     if err != nil {
-        http.Error(w, "Error fetching transactions: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
     // Get categories for the filter form
     categories, err := models.GetAllCategories()
     if err != nil {
-        http.Error(w, "Error fetching categories: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
     // Calculate summary for the current date range
     summary, err := models.GetSummary(filter.StartDate, filter.EndDate)
     if err != nil {
-        http.Error(w, "Error calculating summary: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
@@ -41,37 +57,59 @@ func ListTransactionsHandler(w http.ResponseWriter, r *http.Request) {
         Transactions []models.Transaction
         Categories   []models.Category
         Filter       models.TransactionFilter
+        DateRanges   []periodOption
         Summary      map[string]float64
+        Validator    *validator.Validator
     }{
         Transactions: transactions,
         Categories:   categories,
         Filter:       filter,
+        DateRanges:   dateRangeOptions,
         Summary:      summary,
+        Validator:    v,
     }
     
-    render(w, "transaction_list.html", data)
+    status := http.StatusOK
+    if !v.ValidData() {
+        status = http.StatusUnprocessableEntity
+    }
+    renderStatus(w, status, "transaction_list.html", data)
 }
 
 // GetTransactionFormHandler displays the form to add a new transaction
 func GetTransactionFormHandler(w http.ResponseWriter, r *http.Request) {
     categories, err := models.GetAllCategories()
     if err != nil {
-        http.Error(w, "Error fetching categories: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
+        return
+    }
+
+    // Get accounts for form
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    // Get payees for form
+    payees, err := models.GetAllPayees()
+    if err != nil {
+        renderError(w, r, err)
         return
     }
     
     // Pre-populate with today's date
     transaction := models.Transaction{
-        TransactionDate: time.Now(),
+        TransactionDate: models.Today(),
+        Currency:        models.GetPreferences().Currency,
     }
     
-    data := struct {
-        Transaction models.Transaction
-        Categories  []models.Category
-        Validator   *validator.Validator
-    }{
+    data := transactionFormData{
         Transaction: transaction,
         Categories:  categories,
+        Accounts:    accounts,
+        Payees:      payees,
+        Currencies:  locale.Currencies,
         Validator:   validator.NewValidator(),
     }
     
@@ -82,7 +120,7 @@ func GetTransactionFormHandler(w http.ResponseWriter, r *http.Request) {
 func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     // Parse form data
     if err := r.ParseForm(); err != nil {
-        http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
         return
     }
     
@@ -92,13 +130,32 @@ func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     formData["description"] = r.FormValue("description")
     formData["category_id"] = r.FormValue("category_id")
     formData["transaction_date"] = r.FormValue("transaction_date")
+    formData["tags"] = r.FormValue("tags")
+    formData["account_id"] = r.FormValue("account_id")
+    formData["payee_id"] = r.FormValue("payee_id")
+    formData["currency"] = r.FormValue("currency")
     
     // Parse transaction from form data
     transaction, err := models.ParseTransactionForm(formData)
     if err != nil {
-        http.Error(w, "Error parsing transaction: "+err.Error(), http.StatusBadRequest)
+        renderError(w, r, err)
+        return
+    }
+    
+    // Let the categorization rules fill in the category, tags and description.
+    // A category the form pre-selected from a suggestion was not chosen by
+    // the user, so it only stays when no rule sets one.
+    suggested := 0
+    if r.FormValue("category_suggested") == "1" {
+        suggested, transaction.CategoryID = transaction.CategoryID, 0
+    }
+    if err := models.ApplyRules(transaction); err != nil {
+        renderError(w, r, err)
         return
     }
+    if transaction.CategoryID == 0 {
+        transaction.CategoryID = suggested
+    }
     
     // Validate transaction
     v := validator.NewValidator()
@@ -106,30 +163,19 @@ func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     
     // If validation fails, re-render the form with errors
     if !v.ValidData() {
-        // Get categories for form
-        categories, err := models.GetAllCategories()
-        if err != nil {
-            http.Error(w, "Error fetching categories: "+err.Error(), http.StatusInternalServerError)
-            return
-        }
-        
-        data := struct {
-            Transaction models.Transaction
-            Categories  []models.Category
-            Validator   *validator.Validator
-        }{
-            Transaction: *transaction,
-            Categories:  categories,
-            Validator:   v,
-        }
-        
-        render(w, "transaction_form.html", data)
+        renderTransactionForm(w, r, "transaction_form.html", transaction, v)
         return
     }
     
     // Save transaction to database
-    if err := transaction.Create(); err != nil {
-        http.Error(w, "Error creating transaction: "+err.Error(), http.StatusInternalServerError)
+    if err := transaction.Create(actorFromRequest(r)); err != nil {
+        // The category may have been removed since the form was loaded
+        if errors.Is(err, models.ErrForeignKey) {
+            v.AddError("category_id", "The selected category, account or payee no longer exists")
+            renderTransactionForm(w, r, "transaction_form.html", transaction, v)
+            return
+        }
+        renderError(w, r, err)
         return
     }
     
@@ -143,32 +189,61 @@ func GetTransactionEditHandler(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     id, err := strconv.Atoi(vars["id"])
     if err != nil {
-        http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+        renderError(w, r, models.ErrNotFound)
         return
     }
     
     // Get transaction by ID
     transaction, err := models.GetTransactionByID(id)
     if err != nil {
-        http.Error(w, "Error fetching transaction: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
         return
     }
     
     // Get categories for form
     categories, err := models.GetAllCategories()
     if err != nil {
-        http.Error(w, "Error fetching categories: "+err.Error(), http.StatusInternalServerError)
+        renderError(w, r, err)
+        return
+    }
+
+    // Get accounts for form
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
         return
     }
     
-    data := struct {
-        Transaction models.Transaction
-        Categories  []models.Category
-        Validator   *validator.Validator
-    }{
+    // Get payees for form
+    payees, err := models.GetAllPayees()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    // Get the change log for the transaction
+    history, err := models.GetAuditLog(models.EntityTransaction, id)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    // Get receipts and documents attached to the transaction
+    attachments, err := models.GetAttachments(id)
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    data := transactionFormData{
         Transaction: transaction,
         Categories:  categories,
+        Accounts:    accounts,
+        Payees:      payees,
+        Currencies:  locale.Currencies,
         Validator:   validator.NewValidator(),
+        History:     history,
+        Attachments: attachments,
     }
     
     render(w, "transaction_edit.html", data)
@@ -180,13 +255,13 @@ func UpdateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     id, err := strconv.Atoi(vars["id"])
     if err != nil {
-        http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+        renderError(w, r, models.ErrNotFound)
         return
     }
     
     // Parse form data
     if err := r.ParseForm(); err != nil {
-        http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
         return
     }
     
@@ -197,11 +272,15 @@ func UpdateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     formData["description"] = r.FormValue("description")
     formData["category_id"] = r.FormValue("category_id")
     formData["transaction_date"] = r.FormValue("transaction_date")
+    formData["tags"] = r.FormValue("tags")
+    formData["account_id"] = r.FormValue("account_id")
+    formData["payee_id"] = r.FormValue("payee_id")
+    formData["currency"] = r.FormValue("currency")
     
     // Parse transaction from form data
     transaction, err := models.ParseTransactionForm(formData)
     if err != nil {
-        http.Error(w, "Error parsing transaction: "+err.Error(), http.StatusBadRequest)
+        renderError(w, r, err)
         return
     }
     
@@ -211,30 +290,18 @@ func UpdateTransactionHandler(w http.ResponseWriter, r *http.Request) {
     
     // If validation fails, re-render the form with errors
     if !v.ValidData() {
-        // Get categories for form
-        categories, err := models.GetAllCategories()
-        if err != nil {
-            http.Error(w, "Error fetching categories: "+err.Error(), http.StatusInternalServerError)
-            return
-        }
-        
-        data := struct {
-            Transaction models.Transaction
-            Categories  []models.Category
-            Validator   *validator.Validator
-        }{
-            Transaction: *transaction,
-            Categories:  categories,
-            Validator:   v,
-        }
-        
-        render(w, "transaction_edit.html", data)
+        renderTransactionForm(w, r, "transaction_edit.html", transaction, v)
         return
     }
     
     // Update transaction in database
-    if err := transaction.Update(); err != nil {
-        http.Error(w, "Error updating transaction: "+err.Error(), http.StatusInternalServerError)
+    if err := transaction.Update(actorFromRequest(r)); err != nil {
+        if errors.Is(err, models.ErrForeignKey) {
+            v.AddError("category_id", "The selected category, account or payee no longer exists")
+            renderTransactionForm(w, r, "transaction_edit.html", transaction, v)
+            return
+        }
+        renderError(w, r, err)
         return
     }
     
@@ -248,7 +315,7 @@ func DeleteTransactionHandler(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     id, err := strconv.Atoi(vars["id"])
     if err != nil {
-        http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+        renderError(w, r, models.ErrNotFound)
         return
     }
     
@@ -256,8 +323,8 @@ func DeleteTransactionHandler(w http.ResponseWriter, r *http.Request) {
     transaction := &models.Transaction{ID: id}
     
     // Delete transaction from database
-    if err := transaction.Delete(); err != nil {
-        http.Error(w, "Error deleting transaction: "+err.Error(), http.StatusInternalServerError)
+    if err := transaction.Delete(actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
         return
     }
     
@@ -265,56 +332,148 @@ func DeleteTransactionHandler(w http.ResponseWriter, r *http.Request) {
     http.Redirect(w, r, "/transactions", http.StatusSeeOther)
 }
 
-// Helper function to parse transaction filter from request
-func parseTransactionFilter(r *http.Request) models.TransactionFilter {
-    filter := models.TransactionFilter{}
+// renderTransactionForm re-renders the add or edit form with validation errors
+func renderTransactionForm(w http.ResponseWriter, r *http.Request, tmpl string, transaction *models.Transaction, v *validator.Validator) {
+    // Get categories for form
+    categories, err := models.GetAllCategories()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    // Get accounts for form
+    accounts, err := models.GetAllAccounts()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    // Get payees for form
+    payees, err := models.GetAllPayees()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+    
+    data := transactionFormData{
+        Transaction: *transaction,
+        Categories:  categories,
+        Accounts:    accounts,
+        Payees:      payees,
+        Currencies:  locale.Currencies,
+        Validator:   v,
+    }
     
-    // Parse category ID filter
-    if categoryID := r.URL.Query().Get("category_id"); categoryID != "" {
-        id, err := strconv.Atoi(categoryID)
-        if err == nil && id > 0 {
-            filter.CategoryID = id
+    // The edit page also shows the change log and attachments
+    if transaction.ID > 0 {
+        data.History, err = models.GetAuditLog(models.EntityTransaction, transaction.ID)
+        if err != nil {
+            renderError(w, r, err)
+            return
         }
+        
+        data.Attachments, err = models.GetAttachments(transaction.ID)
+        if err != nil {
+            renderError(w, r, err)
+            return
+        }
+    }
+    
+    renderStatus(w, http.StatusUnprocessableEntity, tmpl, data)
+}
+
+// Helper function to parse transaction filter from request. Values that
+// cannot be parsed are reported on v. Dates default to the current budget
+// period.
+func parseTransactionFilter(r *http.Request, v *validator.Validator) models.TransactionFilter {
+    start, end, _ := models.DateRangePreset("this_period", models.Today())
+    return parseFilter(r, v, start, end)
+}
+
+// parseFilter reads the transaction filters, using start and end for dates
+// that are not given. Zero dates leave that side of the range open.
+func parseFilter(r *http.Request, v *validator.Validator, start, end time.Time) models.TransactionFilter {
+    filter := models.TransactionFilter{}
+    query := r.URL.Query()
+    
+    // Parse the category, account and payee filters
+    filter.CategoryID = parseFilterID(v, query.Get("category_id"), "category_id", "Category")
+    filter.AccountID = parseFilterID(v, query.Get("account_id"), "account_id", "Account")
+    filter.PayeeID = parseFilterID(v, query.Get("payee_id"), "payee_id", "Payee")
+    
+    // Parse tag filter
+    if tag := query.Get("tag"); tag != "" {
+        filter.Tag = strings.ToLower(strings.TrimSpace(tag))
     }
     
     // Parse category type filter
-    if categoryType := r.URL.Query().Get("type"); categoryType == "income" || categoryType == "expense" {
+    switch categoryType := query.Get("type"); categoryType {
+    case "":
+    case "income", "expense":
         filter.CategoryType = categoryType
+    default:
+        v.AddError("type", "Type must be income or expense")
     }
     
-    // Parse date range filters
-    if startDate := r.URL.Query().Get("start_date"); startDate != "" {
-        date, err := time.Parse("2006-01-02", startDate)
-        if err == nil {
-            filter.StartDate = date
+    // Parse the date range: a preset, or start and end dates that may be
+    // relative to today
+    today := models.Today()
+    filter.StartDate, filter.EndDate = start, end
+    if preset := query.Get("range"); preset != "" {
+        start, end, err := models.DateRangePreset(preset, today)
+        if err != nil {
+            v.AddError("range", "Please choose a valid date range")
+        } else {
+            filter.StartDate, filter.EndDate, filter.Range = start, end, preset
         }
     } else {
-        // Default to first day of current month
-        now := time.Now()
-        filter.StartDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-    }
-    
-    if endDate := r.URL.Query().Get("end_date"); endDate != "" {
-        date, err := time.Parse("2006-01-02", endDate)
-        if err == nil {
-            filter.EndDate = date
+        if value := query.Get("start_date"); value != "" {
+            date, err := models.ParseDate(value, today)
+            if err != nil {
+                v.AddError("start_date", "Start date must look like 2024-01-31, today, or -30d")
+            } else {
+                filter.StartDate = date
+            }
+        }
+        
+        if value := query.Get("end_date"); value != "" {
+            date, err := models.ParseDate(value, today)
+            if err != nil {
+                v.AddError("end_date", "End date must look like 2024-01-31, today, or -30d")
+            } else {
+                filter.EndDate = date
+            }
+        }
+        
+        if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() {
+            v.Check(!filter.StartDate.After(filter.EndDate), "start_date", "Start date cannot be after the end date")
         }
-    } else {
-        // Default to last day of current month
-        now := time.Now()
-        filter.EndDate = time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
     }
     
     // Parse sorting options
-    if sortBy := r.URL.Query().Get("sort_by"); sortBy != "" {
+    if sortBy := query.Get("sort_by"); sortBy != "" {
         filter.SortBy = sortBy
     }
     
-    if sortDir := r.URL.Query().Get("sort_dir"); sortDir == "ASC" {
+    if sortDir := query.Get("sort_dir"); sortDir == "ASC" {
         filter.SortDirection = "ASC"
     } else {
         filter.SortDirection = "DESC"
     }
     
     return filter
-}
\ No newline at end of file
+}
+
+// parseFilterID reads an ID filter, reporting anything but a positive
+// number on v
+func parseFilterID(v *validator.Validator, value, field, label string) int {
+    if value == "" {
+        return 0
+    }
+    id, err := strconv.Atoi(value)
+    if err != nil || id <= 0 {
+        v.AddError(field, label+" must be a valid ID")
+        return 0
+    }
+    return id
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/models"
+)
+
+// trashRetention is how long deleted transactions are kept before the
+// background purge removes them; zero keeps them until purged by hand
+var trashRetention time.Duration
+
+// SetTrashRetention configures the retention period shown on the trash page
+func SetTrashRetention(retention time.Duration) {
+    trashRetention = retention
+}
+
+// TrashHandler lists the transactions that have been deleted but not purged
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+    transactions, err := models.GetDeletedTransactions()
+    if err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    data := struct {
+        Transactions  []models.Transaction
+        RetentionDays int
+    }{
+        Transactions:  transactions,
+        RetentionDays: int(trashRetention.Hours() / 24),
+    }
+
+    render(w, "trash.html", data)
+}
+
+// RestoreTransactionHandler moves a transaction out of the trash
+func RestoreTransactionHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    transaction := &models.Transaction{ID: id}
+    if err := transaction.Restore(actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// PurgeTransactionHandler permanently removes a transaction from the trash
+func PurgeTransactionHandler(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        renderError(w, r, models.ErrNotFound)
+        return
+    }
+
+    transaction := &models.Transaction{ID: id}
+    if err := transaction.Purge(actorFromRequest(r)); err != nil {
+        renderError(w, r, err)
+        return
+    }
+
+    http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
@@ -0,0 +1,211 @@
+// Package locale formats numbers, money and dates the way a locale writes
+// them. It covers a fixed set of locales and currencies rather than the full
+// CLDR data.
+package locale
+
+import (
+    "math"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Locale describes how a locale writes numbers and dates
+type Locale struct {
+    Tag         string // BCP 47 tag, such as en-US
+    Name        string
+    Decimal     string
+    Group       string
+    SymbolAfter bool   // Write 12,50 € rather than €12.50
+    DateLayout  string // time.Format layout for a date
+    MonthLayout string // time.Format layout for a month
+}
+
+// Locales lists the supported locales, in the order they are offered
+var Locales = []Locale{
+    {Tag: "en-US", Name: "English (United States)", Decimal: ".", Group: ",", DateLayout: "Jan 02, 2006", MonthLayout: "January 2006"},
+    {Tag: "en-GB", Name: "English (United Kingdom)", Decimal: ".", Group: ",", DateLayout: "02 Jan 2006", MonthLayout: "January 2006"},
+    {Tag: "en-CA", Name: "English (Canada)", Decimal: ".", Group: ",", DateLayout: "2006-01-02", MonthLayout: "January 2006"},
+    {Tag: "de-DE", Name: "Deutsch (Deutschland)", Decimal: ",", Group: ".", SymbolAfter: true, DateLayout: "02.01.2006", MonthLayout: "01/2006"},
+    {Tag: "fr-FR", Name: "Français (France)", Decimal: ",", Group: "\u202f", SymbolAfter: true, DateLayout: "02/01/2006", MonthLayout: "01/2006"},
+    {Tag: "es-ES", Name: "Español (España)", Decimal: ",", Group: ".", SymbolAfter: true, DateLayout: "02/01/2006", MonthLayout: "01/2006"},
+    {Tag: "ja-JP", Name: "日本語 (日本)", Decimal: ".", Group: ",", DateLayout: "2006/01/02", MonthLayout: "2006/01"},
+}
+
+// Currency is an ISO 4217 currency with the symbol used to write amounts
+type Currency struct {
+    Code     string
+    Symbol   string
+    Decimals int
+}
+
+// Currencies lists the currencies with a known symbol, in the order they
+// are offered
+var Currencies = []Currency{
+    {Code: "USD", Symbol: "$", Decimals: 2},
+    {Code: "EUR", Symbol: "€", Decimals: 2},
+    {Code: "GBP", Symbol: "£", Decimals: 2},
+    {Code: "CAD", Symbol: "CA$", Decimals: 2},
+    {Code: "AUD", Symbol: "A$", Decimals: 2},
+    {Code: "NZD", Symbol: "NZ$", Decimals: 2},
+    {Code: "CHF", Symbol: "CHF", Decimals: 2},
+    {Code: "JPY", Symbol: "¥", Decimals: 0},
+    {Code: "CNY", Symbol: "CN¥", Decimals: 2},
+    {Code: "INR", Symbol: "₹", Decimals: 2},
+    {Code: "SEK", Symbol: "kr", Decimals: 2},
+    {Code: "MXN", Symbol: "MX$", Decimals: 2},
+}
+
+// isoCodes lists the active ISO 4217 currency codes
+var isoCodes = strings.Fields(`
+    AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF
+    BMD BND BOB BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC
+    CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS
+    GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD
+    JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL
+    LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD
+    NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD
+    RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP
+    SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES
+    VND VUV WST XAF XCD XOF XPF YER ZAR ZMW ZWL
+`)
+
+// IsCurrency reports whether code is an active ISO 4217 currency code
+func IsCurrency(code string) bool {
+    for _, c := range isoCodes {
+        if c == code {
+            return true
+        }
+    }
+    return false
+}
+
+// Lookup returns the locale with the given tag
+func Lookup(tag string) (Locale, bool) {
+    for _, l := range Locales {
+        if l.Tag == tag {
+            return l, true
+        }
+    }
+    return Locale{}, false
+}
+
+// LookupCurrency returns the currency with the given code. Codes without a
+// known symbol are written with the code itself and two decimals.
+func LookupCurrency(code string) (Currency, bool) {
+    for _, c := range Currencies {
+        if c.Code == code {
+            return c, true
+        }
+    }
+    return Currency{Code: code, Symbol: code, Decimals: 2}, false
+}
+
+// Formatter writes values for one locale, with amounts in one currency
+type Formatter struct {
+    Locale   Locale
+    Currency Currency
+}
+
+// NewFormatter returns a Formatter for a locale tag and currency code,
+// falling back to en-US for unknown locales
+func NewFormatter(tag, currency string) Formatter {
+    l, ok := Lookup(tag)
+    if !ok {
+        l = Locales[0]
+    }
+    c, _ := LookupCurrency(currency)
+    return Formatter{Locale: l, Currency: c}
+}
+
+// Number writes v with the given number of decimals and grouped thousands
+func (f Formatter) Number(v float64, decimals int) string {
+    s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+    whole, fraction, _ := strings.Cut(s, ".")
+
+    var b strings.Builder
+    if v < 0 && !roundsToZero(v, decimals) {
+        b.WriteString("-")
+    }
+    for i, digit := range whole {
+        if i > 0 && (len(whole)-i)%3 == 0 {
+            b.WriteString(f.Locale.Group)
+        }
+        b.WriteRune(digit)
+    }
+    if fraction != "" {
+        b.WriteString(f.Locale.Decimal)
+        b.WriteString(fraction)
+    }
+    return b.String()
+}
+
+// Money writes an amount in the formatter's currency, such as $1,234.50 or
+// 1.234,50 €
+func (f Formatter) Money(v float64) string {
+    return f.MoneyIn(v, f.Currency)
+}
+
+// MoneyIn writes an amount in the given currency
+func (f Formatter) MoneyIn(v float64, c Currency) string {
+    number := f.Number(math.Abs(v), c.Decimals)
+    sign := ""
+    if v < 0 && !roundsToZero(v, c.Decimals) {
+        sign = "-"
+    }
+    return f.withSymbol(sign, number, c)
+}
+
+// withSymbol places the currency symbol where the locale writes it. Codes
+// used as symbols, such as CHF, are set apart by a space.
+func (f Formatter) withSymbol(sign, number string, c Currency) string {
+    if f.Locale.SymbolAfter {
+        return sign + number + "\u00a0" + c.Symbol
+    }
+    if c.Symbol == c.Code {
+        return sign + c.Symbol + "\u00a0" + number
+    }
+    return sign + c.Symbol + number
+}
+
+// Compact writes an amount rounded for chart labels, such as $950, $12.5k
+// or -1,2M €
+func (f Formatter) Compact(v float64) string {
+    abs := math.Abs(v)
+    var number string
+    switch {
+    case abs >= 1e6:
+        number = strings.TrimSuffix(f.Number(abs/1e6, 1), f.Locale.Decimal+"0") + "M"
+    case abs >= 1e3:
+        number = strings.TrimSuffix(f.Number(abs/1e3, 1), f.Locale.Decimal+"0") + "k"
+    default:
+        number = f.Number(abs, 0)
+    }
+
+    sign := ""
+    if v < 0 && number != "0" {
+        sign = "-"
+    }
+    return f.withSymbol(sign, number, f.Currency)
+}
+
+// Percent writes v, already a percentage, with the given number of decimals
+func (f Formatter) Percent(v float64, decimals int) string {
+    return f.Number(v, decimals) + "%"
+}
+
+// Date writes a date, such as Jan 02, 2006 or 02.01.2006
+func (f Formatter) Date(t time.Time) string {
+    return t.Format(f.Locale.DateLayout)
+}
+
+// Month writes the month t falls in, such as January 2006
+func (f Formatter) Month(t time.Time) string {
+    return t.Format(f.Locale.MonthLayout)
+}
+
+// roundsToZero reports whether v is written as zero with the given number of
+// decimals, so it is not shown as -0.00
+func roundsToZero(v float64, decimals int) bool {
+    return strings.Trim(strconv.FormatFloat(math.Abs(v), 'f', decimals, 64), "0.") == ""
+}
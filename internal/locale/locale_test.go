@@ -0,0 +1,140 @@
+package locale
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNumber(t *testing.T) {
+    tests := []struct {
+        locale   string
+        value    float64
+        decimals int
+        want     string
+    }{
+        {"en-US", 0, 2, "0.00"},
+        {"en-US", 999, 0, "999"},
+        {"en-US", 1000, 0, "1,000"},
+        {"en-US", 1234567.891, 2, "1,234,567.89"},
+        {"en-US", -1234.5, 2, "-1,234.50"},
+        {"en-US", -0.001, 2, "0.00"}, // Not -0.00
+        {"de-DE", 1234567.891, 2, "1.234.567,89"},
+        {"fr-FR", 1234.5, 2, "1\u202f234,50"},
+        {"ja-JP", 1234.6, 0, "1,235"},
+        {"xx-XX", 1234.5, 1, "1,234.5"}, // Unknown locales fall back to en-US
+    }
+
+    for _, tt := range tests {
+        f := NewFormatter(tt.locale, "USD")
+        if got := f.Number(tt.value, tt.decimals); got != tt.want {
+            t.Errorf("%s Number(%v, %d) = %q, want %q", tt.locale, tt.value, tt.decimals, got, tt.want)
+        }
+    }
+}
+
+func TestMoney(t *testing.T) {
+    tests := []struct {
+        locale   string
+        currency string
+        value    float64
+        want     string
+    }{
+        {"en-US", "USD", 1234.5, "$1,234.50"},
+        {"en-US", "USD", -1234.5, "-$1,234.50"},
+        {"en-US", "USD", -0.004, "$0.00"},
+        {"en-GB", "GBP", 12, "£12.00"},
+        {"en-US", "JPY", 1234.6, "¥1,235"},
+        {"en-US", "CHF", 50, "CHF\u00a050.00"},
+        {"en-US", "PLN", 50, "PLN\u00a050.00"}, // No known symbol
+        {"de-DE", "EUR", 1234.5, "1.234,50\u00a0€"},
+        {"de-DE", "EUR", -1234.5, "-1.234,50\u00a0€"},
+        {"fr-FR", "EUR", 1234.5, "1\u202f234,50\u00a0€"},
+    }
+
+    for _, tt := range tests {
+        f := NewFormatter(tt.locale, tt.currency)
+        if got := f.Money(tt.value); got != tt.want {
+            t.Errorf("%s %s Money(%v) = %q, want %q", tt.locale, tt.currency, tt.value, got, tt.want)
+        }
+    }
+}
+
+func TestMoneyIn(t *testing.T) {
+    f := NewFormatter("en-US", "USD")
+    eur, _ := LookupCurrency("EUR")
+    if got, want := f.MoneyIn(12.5, eur), "€12.50"; got != want {
+        t.Errorf("MoneyIn(12.5, EUR) = %q, want %q", got, want)
+    }
+}
+
+func TestCompact(t *testing.T) {
+    tests := []struct {
+        locale   string
+        currency string
+        value    float64
+        want     string
+    }{
+        {"en-US", "USD", 0, "$0"},
+        {"en-US", "USD", 950, "$950"},
+        {"en-US", "USD", 1000, "$1k"},
+        {"en-US", "USD", 12500, "$12.5k"},
+        {"en-US", "USD", -2500000, "-$2.5M"},
+        {"en-US", "USD", -0.4, "$0"},
+        {"de-DE", "EUR", -1200000, "-1,2M\u00a0€"},
+    }
+
+    for _, tt := range tests {
+        f := NewFormatter(tt.locale, tt.currency)
+        if got := f.Compact(tt.value); got != tt.want {
+            t.Errorf("%s %s Compact(%v) = %q, want %q", tt.locale, tt.currency, tt.value, got, tt.want)
+        }
+    }
+}
+
+func TestPercentDateMonth(t *testing.T) {
+    date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        locale      string
+        wantPercent string
+        wantDate    string
+        wantMonth   string
+    }{
+        {"en-US", "12.5%", "Mar 05, 2024", "March 2024"},
+        {"en-GB", "12.5%", "05 Mar 2024", "March 2024"},
+        {"de-DE", "12,5%", "05.03.2024", "03/2024"},
+        {"ja-JP", "12.5%", "2024/03/05", "2024/03"},
+    }
+
+    for _, tt := range tests {
+        f := NewFormatter(tt.locale, "USD")
+        if got := f.Percent(12.5, 1); got != tt.wantPercent {
+            t.Errorf("%s Percent = %q, want %q", tt.locale, got, tt.wantPercent)
+        }
+        if got := f.Date(date); got != tt.wantDate {
+            t.Errorf("%s Date = %q, want %q", tt.locale, got, tt.wantDate)
+        }
+        if got := f.Month(date); got != tt.wantMonth {
+            t.Errorf("%s Month = %q, want %q", tt.locale, got, tt.wantMonth)
+        }
+    }
+}
+
+func TestIsCurrency(t *testing.T) {
+    tests := []struct {
+        code string
+        want bool
+    }{
+        {"USD", true},
+        {"PLN", true},
+        {"usd", false},
+        {"XYZ", false},
+        {"", false},
+    }
+
+    for _, tt := range tests {
+        if got := IsCurrency(tt.code); got != tt.want {
+            t.Errorf("IsCurrency(%q) = %v, want %v", tt.code, got, tt.want)
+        }
+    }
+}
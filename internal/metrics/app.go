@@ -0,0 +1,58 @@
+package metrics
+
+import (
+    "database/sql"
+)
+
+var (
+    // HTTPRequests counts finished requests by route template and status
+    HTTPRequests = NewCounterVec(
+        "http_requests_total",
+        "Total HTTP requests by method, route template and status code.",
+        "method", "route", "status",
+    )
+
+    // HTTPRequestDuration tracks request latency by route template
+    HTTPRequestDuration = NewHistogramVec(
+        "http_request_duration_seconds",
+        "HTTP request latency in seconds by method and route template.",
+        DefaultBuckets,
+        "method", "route",
+    )
+
+    // TemplateRenderDuration tracks how long each page template takes to execute
+    TemplateRenderDuration = NewHistogramVec(
+        "template_render_duration_seconds",
+        "Time spent executing page templates in seconds.",
+        DefaultBuckets,
+        "template",
+    )
+)
+
+// RegisterDBStats exposes the connection pool statistics of db
+func RegisterDBStats(db *sql.DB) {
+    NewGaugeFunc("db_open_connections", "Established connections, both in use and idle.", func() float64 {
+        return float64(db.Stats().OpenConnections)
+    })
+    NewGaugeFunc("db_in_use_connections", "Connections currently in use.", func() float64 {
+        return float64(db.Stats().InUse)
+    })
+    NewGaugeFunc("db_idle_connections", "Idle connections in the pool.", func() float64 {
+        return float64(db.Stats().Idle)
+    })
+    NewGaugeFunc("db_max_open_connections", "Maximum number of open connections allowed.", func() float64 {
+        return float64(db.Stats().MaxOpenConnections)
+    })
+    NewCounterFunc("db_wait_count_total", "Total connections waited for.", func() float64 {
+        return float64(db.Stats().WaitCount)
+    })
+    NewCounterFunc("db_wait_duration_seconds_total", "Total time blocked waiting for a connection.", func() float64 {
+        return db.Stats().WaitDuration.Seconds()
+    })
+    NewCounterFunc("db_max_idle_closed_total", "Connections closed due to the idle connection limit.", func() float64 {
+        return float64(db.Stats().MaxIdleClosed)
+    })
+    NewCounterFunc("db_max_lifetime_closed_total", "Connections closed due to the maximum lifetime.", func() float64 {
+        return float64(db.Stats().MaxLifetimeClosed)
+    })
+}
@@ -0,0 +1,259 @@
+// Package metrics implements the small subset of the Prometheus text
+// exposition format the application needs: labelled counters, histograms
+// and gauges whose values are read at scrape time.
+package metrics
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// collector is anything that can write itself in the exposition format
+type collector interface {
+    write(w io.Writer)
+}
+
+var (
+    registryMu sync.Mutex
+    registry   []collector
+)
+
+func register(c collector) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+    registry = append(registry, c)
+}
+
+// Handler serves every registered metric in the Prometheus text format
+func Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+        registryMu.Lock()
+        collectors := append([]collector(nil), registry...)
+        registryMu.Unlock()
+
+        bw := bufio.NewWriter(w)
+        for _, c := range collectors {
+            c.write(bw)
+        }
+        bw.Flush()
+    })
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+    name   string
+    help   string
+    labels []string
+
+    mu     sync.Mutex
+    series map[string]*counterSeries
+}
+
+type counterSeries struct {
+    labelValues []string
+    value       float64
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+    c := &CounterVec{name: name, help: help, labels: labels, series: make(map[string]*counterSeries)}
+    register(c)
+    return c
+}
+
+// Inc adds one to the series identified by labelValues
+func (c *CounterVec) Inc(labelValues ...string) {
+    c.Add(1, labelValues...)
+}
+
+// Add adds v to the series identified by labelValues
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+    key := strings.Join(labelValues, "\xff")
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    s, ok := c.series[key]
+    if !ok {
+        s = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+        c.series[key] = s
+    }
+    s.value += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+    writeHeader(w, c.name, c.help, "counter")
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for _, key := range sortedKeys(c.series) {
+        s := c.series[key]
+        fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.labelValues), formatValue(s.value))
+    }
+}
+
+// DefaultBuckets suit request and render latencies measured in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+    name    string
+    help    string
+    labels  []string
+    buckets []float64
+
+    mu     sync.Mutex
+    series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+    labelValues []string
+    counts      []uint64 // per bucket, not cumulative
+    count       uint64
+    sum         float64
+}
+
+// NewHistogramVec creates and registers a histogram with the given upper bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+    sorted := append([]float64(nil), buckets...)
+    sort.Float64s(sorted)
+
+    h := &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, series: make(map[string]*histogramSeries)}
+    register(h)
+    return h
+}
+
+// Observe records v in the series identified by labelValues
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+    key := strings.Join(labelValues, "\xff")
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    s, ok := h.series[key]
+    if !ok {
+        s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+        h.series[key] = s
+    }
+
+    for i, upper := range h.buckets {
+        if v <= upper {
+            s.counts[i]++
+            break
+        }
+    }
+    s.count++
+    s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+    writeHeader(w, h.name, h.help, "histogram")
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    bucketLabels := append(append([]string(nil), h.labels...), "le")
+
+    for _, key := range sortedKeys(h.series) {
+        s := h.series[key]
+
+        var cumulative uint64
+        for i, upper := range h.buckets {
+            cumulative += s.counts[i]
+            values := append(append([]string(nil), s.labelValues...), formatValue(upper))
+            fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), cumulative)
+        }
+        values := append(append([]string(nil), s.labelValues...), "+Inf")
+        fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), s.count)
+
+        labels := formatLabels(h.labels, s.labelValues)
+        fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatValue(s.sum))
+        fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+    }
+}
+
+// funcMetric reports a single value computed at scrape time
+type funcMetric struct {
+    name  string
+    help  string
+    kind  string
+    value func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn on every scrape
+func NewGaugeFunc(name, help string, fn func() float64) {
+    register(&funcMetric{name: name, help: help, kind: "gauge", value: fn})
+}
+
+// NewCounterFunc registers a counter whose value is read from fn on every
+// scrape. fn must return a value that never decreases.
+func NewCounterFunc(name, help string, fn func() float64) {
+    register(&funcMetric{name: name, help: help, kind: "counter", value: fn})
+}
+
+func (m *funcMetric) write(w io.Writer) {
+    writeHeader(w, m.name, m.help, m.kind)
+    fmt.Fprintf(w, "%s %s\n", m.name, formatValue(m.value()))
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+    help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+    fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+    if len(names) == 0 {
+        return ""
+    }
+
+    var b strings.Builder
+    b.WriteByte('{')
+    for i, name := range names {
+        if i > 0 {
+            b.WriteByte(',')
+        }
+        value := ""
+        if i < len(values) {
+            value = values[i]
+        }
+        b.WriteString(name)
+        b.WriteString(`="`)
+        b.WriteString(labelEscaper.Replace(value))
+        b.WriteByte('"')
+    }
+    b.WriteByte('}')
+    return b.String()
+}
+
+func formatValue(v float64) string {
+    switch {
+    case math.IsInf(v, 1):
+        return "+Inf"
+    case math.IsInf(v, -1):
+        return "-Inf"
+    case math.IsNaN(v):
+        return "NaN"
+    }
+    return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+    "compress/gzip"
+    "io"
+    "mime"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/andybalholm/brotli"
+)
+
+// compressibleTypes lists the media types worth compressing; images other
+// than SVG, PDFs and archives are already compressed
+var compressibleTypes = map[string]bool{
+    "text/html":              true,
+    "text/css":               true,
+    "text/plain":             true,
+    "text/csv":               true,
+    "text/javascript":        true,
+    "application/javascript": true,
+    "application/json":       true,
+    "application/xml":        true,
+    "image/svg+xml":          true,
+}
+
+var gzipPool = sync.Pool{
+    New: func() interface{} {
+        w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+        return w
+    },
+}
+
+var brotliPool = sync.Pool{
+    New: func() interface{} {
+        return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+    },
+}
+
+// Compress encodes compressible responses with Brotli or gzip, whichever the
+// client prefers according to Accept-Encoding
+func Compress(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Add("Vary", "Accept-Encoding")
+
+        encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+        if encoding == "" || r.Method == http.MethodHead {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+        defer cw.Close()
+
+        next.ServeHTTP(cw, r)
+    })
+}
+
+// negotiateEncoding picks "br" or "gzip" from an Accept-Encoding header,
+// honouring q-values and preferring Brotli when both are equally acceptable
+func negotiateEncoding(header string) string {
+    if header == "" {
+        return ""
+    }
+
+    quality := map[string]float64{}
+    for _, part := range strings.Split(header, ",") {
+        name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        name = strings.ToLower(strings.TrimSpace(name))
+        q := 1.0
+        if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+            if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+                q = parsed
+            }
+        }
+        quality[name] = q
+    }
+
+    best, bestQ := "", 0.0
+    for _, candidate := range []string{"br", "gzip"} {
+        q, ok := quality[candidate]
+        if !ok {
+            q, ok = quality["*"]
+        }
+        if ok && q > bestQ {
+            best, bestQ = candidate, q
+        }
+    }
+    return best
+}
+
+// compressWriter decides whether to compress when the status is written,
+// based on the response's Content-Type
+type compressWriter struct {
+    http.ResponseWriter
+    encoding    string
+    encoder     io.WriteCloser
+    wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+    if cw.wroteHeader {
+        return
+    }
+    cw.wroteHeader = true
+
+    h := cw.Header()
+    if shouldCompress(code, h) {
+        h.Set("Content-Encoding", cw.encoding)
+        h.Del("Content-Length")
+        h.Del("Accept-Ranges")
+        cw.encoder = cw.newEncoder()
+    }
+
+    cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+    if !cw.wroteHeader {
+        if cw.Header().Get("Content-Type") == "" {
+            cw.Header().Set("Content-Type", http.DetectContentType(b))
+        }
+        cw.WriteHeader(http.StatusOK)
+    }
+
+    if cw.encoder != nil {
+        return cw.encoder.Write(b)
+    }
+    return cw.ResponseWriter.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client
+func (cw *compressWriter) Flush() {
+    if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+        f.Flush()
+    }
+    http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close finishes the compressed stream and returns the encoder to its pool
+func (cw *compressWriter) Close() error {
+    if cw.encoder == nil {
+        return nil
+    }
+
+    err := cw.encoder.Close()
+    switch enc := cw.encoder.(type) {
+    case *gzip.Writer:
+        gzipPool.Put(enc)
+    case *brotli.Writer:
+        brotliPool.Put(enc)
+    }
+    cw.encoder = nil
+    return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+    return cw.ResponseWriter
+}
+
+func (cw *compressWriter) newEncoder() io.WriteCloser {
+    if cw.encoding == "br" {
+        enc := brotliPool.Get().(*brotli.Writer)
+        enc.Reset(cw.ResponseWriter)
+        return enc
+    }
+
+    enc := gzipPool.Get().(*gzip.Writer)
+    enc.Reset(cw.ResponseWriter)
+    return enc
+}
+
+func shouldCompress(code int, h http.Header) bool {
+    if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+        return false
+    }
+    if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+        return false
+    }
+
+    mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+    if err != nil {
+        return false
+    }
+    return compressibleTypes[mediaType]
+}
@@ -1,25 +1,70 @@
 package middleware
 
 import (
-    "log"
+    "log/slog"
     "net/http"
     "time"
 )
 
-// LoggingMiddleware logs information about each HTTP request
+// statusRecorder captures the status code and body size written by a handler
+type statusRecorder struct {
+    http.ResponseWriter
+    status      int
+    bytes       int
+    wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+    if !rec.wroteHeader {
+        rec.status = code
+        rec.wroteHeader = true
+    }
+    rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+    if !rec.wroteHeader {
+        rec.WriteHeader(http.StatusOK)
+    }
+    n, err := rec.ResponseWriter.Write(b)
+    rec.bytes += n
+    return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+    return rec.ResponseWriter
+}
+
+// LoggingMiddleware writes one structured access log entry per request. It
+// expects RequestID and RealIP to run before it.
 func LoggingMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         // Record the start time
         start := time.Now()
-        
-        // Log the request details
-        log.Printf("REQUEST: %s %s FROM %s", r.Method, r.URL.Path, r.RemoteAddr)
+        rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 
         // Call the next handler
-        next.ServeHTTP(w, r)
+        next.ServeHTTP(rec, r)
+
+        // Server errors are logged as errors, client errors as warnings
+        level := slog.LevelInfo
+        switch {
+        case rec.status >= 500:
+            level = slog.LevelError
+        case rec.status >= 400:
+            level = slog.LevelWarn
+        }
 
-        // Calculate and log the response time
-        duration := time.Since(start)
-        log.Printf("RESPONSE: %s %s - completed in %v", r.Method, r.URL.Path, duration)
+        slog.LogAttrs(r.Context(), level, "request",
+            slog.String("request_id", GetRequestID(r.Context())),
+            slog.String("method", r.Method),
+            slog.String("path", r.URL.Path),
+            slog.Int("status", rec.status),
+            slog.Int("bytes", rec.bytes),
+            slog.Duration("duration", time.Since(start)),
+            slog.String("remote_ip", ClientIP(r)),
+            slog.String("user_agent", r.UserAgent()),
+        )
     })
-}
\ No newline at end of file
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+    "context"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/bryan/finance-tracker/internal/metrics"
+)
+
+const routeKey contextKey = "route"
+
+// Metrics records request counts and latencies labelled by the matched route
+// template (e.g. /transactions/{id}/edit) so IDs do not explode the label set.
+// It wraps the whole router so 404 and 405 responses are counted too, as
+// "unmatched"; RouteTemplate reports the template from inside the router.
+func Metrics(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+        route := "unmatched"
+
+        next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeKey, &route)))
+
+        metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(rec.status))
+        metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+    })
+}
+
+// RouteTemplate passes the matched route template back to Metrics. It must
+// be added with the router's Use, which only runs once a route matched.
+func RouteTemplate(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if route, ok := r.Context().Value(routeKey).(*string); ok {
+            if current := mux.CurrentRoute(r); current != nil {
+                if tmpl, err := current.GetPathTemplate(); err == nil {
+                    *route = tmpl
+                }
+            }
+        }
+        next.ServeHTTP(w, r)
+    })
+}
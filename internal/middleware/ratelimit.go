@@ -0,0 +1,58 @@
+package middleware
+
+import (
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/ratelimit"
+)
+
+// KeyFunc identifies the client a request is counted against. An empty key
+// means the limit does not apply to the request.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by the client address resolved by RealIP
+func ByIP(r *http.Request) string {
+    return "ip:" + ClientIP(r)
+}
+
+// RateLimit rejects requests with 429 once the client's bucket is empty and
+// reports the bucket state in RateLimit-* headers
+func RateLimit(limiter *ratelimit.Limiter, key KeyFunc) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            k := key(r)
+            if k == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            result := limiter.Allow(k)
+            setRateLimitHeaders(w, result)
+            if !result.Allowed {
+                tooManyRequests(w, result.RetryAfter)
+                return
+            }
+
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, result ratelimit.Result) {
+    h := w.Header()
+    h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+    h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+    h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+    w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(retryAfter))))
+    http.Error(w, "Too many requests, please slow down", http.StatusTooManyRequests)
+}
+
+func ceilSeconds(d time.Duration) int {
+    return int(math.Ceil(d.Seconds()))
+}
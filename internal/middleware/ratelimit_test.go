@@ -0,0 +1,41 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+    limiter := ratelimit.New(60, time.Hour, 2)
+    handler := RateLimit(limiter, ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+    tests := []struct {
+        wantStatus    int
+        wantRemaining string
+    }{
+        {http.StatusOK, "1"},
+        {http.StatusOK, "0"},
+        {http.StatusTooManyRequests, "0"},
+    }
+
+    for i, tt := range tests {
+        w := httptest.NewRecorder()
+        r := httptest.NewRequest("GET", "/", nil)
+        r.RemoteAddr = "192.0.2.1:1234"
+        handler.ServeHTTP(w, r)
+
+        if w.Code != tt.wantStatus {
+            t.Errorf("request %d: status = %d, want %d", i+1, w.Code, tt.wantStatus)
+        }
+        if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+            t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, tt.wantRemaining)
+        }
+        if tt.wantStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+            t.Errorf("request %d: missing Retry-After", i+1)
+        }
+    }
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+    "context"
+    "net"
+    "net/http"
+    "strings"
+)
+
+const clientIPKey contextKey = "client_ip"
+
+// RealIP resolves the client address for each request. X-Forwarded-For and
+// X-Real-IP are only honoured when the direct peer is one of the trusted
+// proxies; otherwise anyone could spoof their address.
+func RealIP(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ip := resolveClientIP(r, trustedProxies)
+            ctx := context.WithValue(r.Context(), clientIPKey, ip)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+// ClientIP returns the address resolved by RealIP, falling back to the
+// direct peer address when RealIP is not in the chain
+func ClientIP(r *http.Request) string {
+    if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+        return ip
+    }
+    return remoteHost(r.RemoteAddr)
+}
+
+func resolveClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+    peer := remoteHost(r.RemoteAddr)
+    if !isTrusted(peer, trustedProxies) {
+        return peer
+    }
+
+    // Walk X-Forwarded-For from the right, skipping our own proxies; the
+    // first untrusted hop is the client
+    if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+        hops := strings.Split(xff, ",")
+        for i := len(hops) - 1; i >= 0; i-- {
+            hop := strings.TrimSpace(hops[i])
+            if net.ParseIP(hop) == nil {
+                break
+            }
+            if !isTrusted(hop, trustedProxies) {
+                return hop
+            }
+        }
+    }
+
+    if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+        return realIP
+    }
+
+    return peer
+}
+
+func isTrusted(ip string, trustedProxies []*net.IPNet) bool {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return false
+    }
+    for _, network := range trustedProxies {
+        if network.Contains(parsed) {
+            return true
+        }
+    }
+    return false
+}
+
+func remoteHost(addr string) string {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return addr
+    }
+    return host
+}
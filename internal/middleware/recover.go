@@ -0,0 +1,47 @@
+package middleware
+
+import (
+    "log/slog"
+    "net/http"
+    "runtime/debug"
+)
+
+// Recover turns a panic in a handler into a logged error with a stack trace
+// and, if nothing has been written yet, renders the page from onPanic
+func Recover(onPanic http.HandlerFunc) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+            defer func() {
+                err := recover()
+                if err == nil {
+                    return
+                }
+
+                // ErrAbortHandler is the sanctioned way to abort a response
+                if err == http.ErrAbortHandler {
+                    panic(err)
+                }
+
+                slog.Error("Panic recovered",
+                    "request_id", GetRequestID(r.Context()),
+                    "method", r.Method,
+                    "path", r.URL.Path,
+                    "panic", err,
+                    "stack", string(debug.Stack()),
+                )
+
+                // A partially written response cannot be replaced
+                if rec.wroteHeader {
+                    return
+                }
+                w.Header().Del("Content-Encoding")
+                w.Header().Del("Content-Length")
+                onPanic(w, r)
+            }()
+
+            next.ServeHTTP(rec, r)
+        })
+    }
+}
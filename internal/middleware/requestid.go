@@ -0,0 +1,47 @@
+package middleware
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "net/http"
+    "regexp"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// RequestIDHeader is read from incoming requests and set on every response
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits incoming IDs to a safe length and character set so
+// they cannot be used to inject content into logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID assigns an ID to each request, reusing a well-formed X-Request-ID
+// from the client or proxy, and echoes it in the response
+func RequestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get(RequestIDHeader)
+        if !validRequestID.MatchString(id) {
+            id = newRequestID()
+        }
+
+        w.Header().Set(RequestIDHeader, id)
+        ctx := context.WithValue(r.Context(), requestIDKey, id)
+        next.ServeHTTP(w, r.WithContext(ctx))
+    })
+}
+
+// GetRequestID returns the ID assigned by RequestID, or "" if there is none
+func GetRequestID(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey).(string)
+    return id
+}
+
+func newRequestID() string {
+    b := make([]byte, 16)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+    "database/sql"
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// AccountTypes lists the kinds of account a transaction can belong to
+var AccountTypes = []string{"checking", "savings", "credit_card", "cash", "investment", "property", "pension", "loan", "mortgage", "other"}
+
+// liabilityTypes are the account types whose balance is an amount owed
+var liabilityTypes = map[string]bool{"credit_card": true, "loan": true, "mortgage": true}
+
+type Account struct {
+    ID        int       `json:"id"`
+    Name      string    `json:"name"`
+    Type      string    `json:"type"`
+    Currency  string    `json:"currency"` // ISO 4217 code of the account's transactions and balances
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsLiability reports whether the account's balance is money owed. Spending
+// from a liability account increases its balance; income pays it down.
+func (a Account) IsLiability() bool {
+    return liabilityTypes[a.Type]
+}
+
+// Create adds a new account to the database
+func (a *Account) Create() error {
+    stmt := `
+        INSERT INTO accounts (name, type, currency)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, a.Name, a.Type, a.Currency).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+    return dbError(err)
+}
+
+// GetAllAccounts retrieves all accounts ordered by name
+func GetAllAccounts() ([]Account, error) {
+    stmt := `
+        SELECT id, name, type, currency, created_at, updated_at
+        FROM accounts
+        ORDER BY name`
+
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var accounts []Account
+
+    for rows.Next() {
+        var account Account
+        if err := rows.Scan(&account.ID, &account.Name, &account.Type, &account.Currency, &account.CreatedAt, &account.UpdatedAt); err != nil {
+            return nil, err
+        }
+        accounts = append(accounts, account)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return accounts, nil
+}
+
+// GetAccountByID retrieves an account by its ID
+func GetAccountByID(id int) (Account, error) {
+    var account Account
+
+    stmt := `
+        SELECT id, name, type, currency, created_at, updated_at
+        FROM accounts
+        WHERE id = $1`
+
+    err := database.DB.QueryRow(stmt, id).Scan(
+        &account.ID, &account.Name, &account.Type, &account.Currency, &account.CreatedAt, &account.UpdatedAt)
+
+    return account, dbError(err)
+}
+
+// ValidateAccount validates account data
+func ValidateAccount(v *validator.Validator, account *Account) {
+    v.Check(validator.NotBlank(account.Name), "name", "Account name is required")
+    v.Check(validator.MaxLength(account.Name, 100), "name", "Account name cannot exceed 100 characters")
+
+    valid := false
+    for _, t := range AccountTypes {
+        if account.Type == t {
+            valid = true
+        }
+    }
+    v.Check(valid, "type", "Please select a valid account type")
+    v.Check(locale.IsCurrency(account.Currency), "currency", "Please enter a currency code such as USD or EUR")
+}
+
+// ParseCurrency normalizes a currency code entered in a form
+func ParseCurrency(value string) string {
+    return strings.ToUpper(strings.TrimSpace(value))
+}
+
+// nullID maps the zero ID used by the models to SQL NULL
+func nullID(id int) sql.NullInt64 {
+    return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
@@ -0,0 +1,291 @@
+package models
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/storage"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// AttachmentTypes lists the media types that can be attached to a
+// transaction, detected from the file contents rather than the upload
+var AttachmentTypes = map[string]string{
+    "image/jpeg":      "JPEG image",
+    "image/png":       "PNG image",
+    "image/gif":       "GIF image",
+    "image/webp":      "WebP image",
+    "application/pdf": "PDF document",
+}
+
+// Attachment is a receipt or document stored alongside a transaction. The
+// file itself lives in the blob store under StorageKey.
+type Attachment struct {
+    ID            int       `json:"id"`
+    TransactionID int       `json:"transaction_id"`
+    Filename      string    `json:"filename"`
+    ContentType   string    `json:"content_type"`
+    Size          int64     `json:"size"`
+    StorageKey    string    `json:"-"`
+    ThumbnailKey  string    `json:"-"` // Empty when no thumbnail could be made
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+// blobStore holds attachment files; it is set once at startup
+var blobStore storage.Store
+
+// SetBlobStore configures where attachment files are stored
+func SetBlobStore(store storage.Store) {
+    blobStore = store
+}
+
+// HasThumbnail reports whether a preview image is stored for a
+func (a Attachment) HasThumbnail() bool {
+    return a.ThumbnailKey != ""
+}
+
+// SizeString formats the file size for display
+func (a Attachment) SizeString() string {
+    switch {
+    case a.Size >= 1<<20:
+        return fmt.Sprintf("%.1f MB", float64(a.Size)/(1<<20))
+    case a.Size >= 1<<10:
+        return fmt.Sprintf("%.0f KB", float64(a.Size)/(1<<10))
+    default:
+        return fmt.Sprintf("%d bytes", a.Size)
+    }
+}
+
+// Create stores the file and its optional thumbnail and records the
+// attachment. It returns ErrNotFound if the transaction is not live.
+func (a *Attachment) Create(data, thumbnail []byte, actor string) error {
+    if blobStore == nil {
+        return errors.New("attachment storage is not configured")
+    }
+
+    key, err := newAttachmentKey(a.TransactionID)
+    if err != nil {
+        return err
+    }
+
+    ctx := context.Background()
+    if err := blobStore.Put(ctx, key, bytes.NewReader(data)); err != nil {
+        return err
+    }
+    a.StorageKey = key
+    a.Size = int64(len(data))
+
+    if len(thumbnail) > 0 {
+        if err := blobStore.Put(ctx, key+"-thumb", bytes.NewReader(thumbnail)); err != nil {
+            deleteBlobs([]string{key})
+            return err
+        }
+        a.ThumbnailKey = key + "-thumb"
+    }
+
+    stmt := `
+        INSERT INTO attachments (transaction_id, filename, content_type, size_bytes, storage_key, thumbnail_key)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at`
+
+    err = withTx(func(tx *sql.Tx) error {
+        // Lock the transaction so it cannot be purged while attaching
+        if _, err := getTransactionByID(tx, a.TransactionID, true); err != nil {
+            return err
+        }
+
+        err := tx.QueryRow(stmt, a.TransactionID, a.Filename, a.ContentType, a.Size, a.StorageKey, a.ThumbnailKey).Scan(&a.ID, &a.CreatedAt)
+        if err != nil {
+            return err
+        }
+
+        after := map[string]interface{}{"attachment_added": a.Filename}
+        return recordAudit(tx, EntityTransaction, a.TransactionID, ActionUpdate, nil, after, actor)
+    })
+    if err != nil {
+        deleteBlobs(a.blobKeys())
+        return dbError(err)
+    }
+    return nil
+}
+
+// Delete removes the attachment and its files
+func (a *Attachment) Delete(actor string) error {
+    stmt := `
+        DELETE FROM attachments
+        WHERE id = $1 AND transaction_id = $2
+        RETURNING filename, storage_key, thumbnail_key`
+
+    err := withTx(func(tx *sql.Tx) error {
+        if err := tx.QueryRow(stmt, a.ID, a.TransactionID).Scan(&a.Filename, &a.StorageKey, &a.ThumbnailKey); err != nil {
+            return err
+        }
+
+        after := map[string]interface{}{"attachment_removed": a.Filename}
+        return recordAudit(tx, EntityTransaction, a.TransactionID, ActionUpdate, nil, after, actor)
+    })
+    if err != nil {
+        return dbError(err)
+    }
+
+    deleteBlobs(a.blobKeys())
+    return nil
+}
+
+// Open returns the attachment file, or its thumbnail
+func (a Attachment) Open(thumbnail bool) (io.ReadCloser, error) {
+    if blobStore == nil {
+        return nil, errors.New("attachment storage is not configured")
+    }
+
+    key := a.StorageKey
+    if thumbnail {
+        key = a.ThumbnailKey
+    }
+    if key == "" {
+        return nil, ErrNotFound
+    }
+
+    r, err := blobStore.Open(context.Background(), key)
+    if errors.Is(err, storage.ErrNotExist) {
+        return nil, ErrNotFound
+    }
+    return r, err
+}
+
+func (a Attachment) blobKeys() []string {
+    keys := []string{a.StorageKey}
+    if a.ThumbnailKey != "" {
+        keys = append(keys, a.ThumbnailKey)
+    }
+    return keys
+}
+
+const attachmentColumns = `
+        id, transaction_id, filename, content_type, size_bytes, storage_key, thumbnail_key, created_at`
+
+func scanAttachment(row rowScanner, a *Attachment) error {
+    return row.Scan(&a.ID, &a.TransactionID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.ThumbnailKey, &a.CreatedAt)
+}
+
+// GetAttachments retrieves the attachments of a transaction, oldest first
+func GetAttachments(transactionID int) ([]Attachment, error) {
+    stmt := `
+        SELECT` + attachmentColumns + `
+        FROM attachments
+        WHERE transaction_id = $1
+        ORDER BY created_at, id`
+
+    rows, err := database.DB.Query(stmt, transactionID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var attachments []Attachment
+
+    for rows.Next() {
+        var attachment Attachment
+        if err := scanAttachment(rows, &attachment); err != nil {
+            return nil, err
+        }
+        attachments = append(attachments, attachment)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return attachments, nil
+}
+
+// GetAttachment retrieves one attachment of a live transaction
+func GetAttachment(transactionID, id int) (Attachment, error) {
+    var attachment Attachment
+
+    stmt := `
+        SELECT` + attachmentColumns + `
+        FROM attachments
+        WHERE id = $1 AND transaction_id = $2
+            AND EXISTS (SELECT 1 FROM transactions t WHERE t.id = transaction_id AND t.deleted_at IS NULL)`
+
+    err := scanAttachment(database.DB.QueryRow(stmt, id, transactionID), &attachment)
+    return attachment, dbError(err)
+}
+
+// attachmentBlobKeys returns the stored files of a transaction's attachments
+func attachmentBlobKeys(q queryer, transactionID int) ([]string, error) {
+    rows, err := q.Query(`SELECT storage_key, thumbnail_key FROM attachments WHERE transaction_id = $1`, transactionID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var keys []string
+    for rows.Next() {
+        var a Attachment
+        if err := rows.Scan(&a.StorageKey, &a.ThumbnailKey); err != nil {
+            return nil, err
+        }
+        keys = append(keys, a.blobKeys()...)
+    }
+    return keys, rows.Err()
+}
+
+// deleteBlobs removes files whose database rows are gone. Failures only
+// leave orphaned files behind, so they are logged rather than returned.
+func deleteBlobs(keys []string) {
+    if blobStore == nil {
+        return
+    }
+    for _, key := range keys {
+        if err := blobStore.Delete(context.Background(), key); err != nil {
+            slog.Warn("Deleting attachment file failed", "key", key, "err", err)
+        }
+    }
+}
+
+// newAttachmentKey returns an unguessable storage key for a new file
+func newAttachmentKey(transactionID int) (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("attachments/%d/%s", transactionID, hex.EncodeToString(b)), nil
+}
+
+// ValidateAttachment validates an uploaded file before it is stored
+func ValidateAttachment(v *validator.Validator, attachment *Attachment, maxSize int64) {
+    v.Check(validator.NotBlank(attachment.Filename), "attachment", "The file needs a name")
+    v.Check(validator.MaxLength(attachment.Filename, 255), "attachment", "File name cannot exceed 255 characters")
+    v.Check(attachment.Size > 0, "attachment", "The file is empty")
+    v.Check(attachment.Size <= maxSize, "attachment", fmt.Sprintf("Files cannot be larger than %s", Attachment{Size: maxSize}.SizeString()))
+
+    _, ok := AttachmentTypes[attachment.ContentType]
+    v.Check(ok, "attachment", "Only JPEG, PNG, GIF and WebP images and PDF documents can be attached")
+}
+
+// CleanFilename keeps the base name of an uploaded file and drops
+// characters that are unsafe in headers and file systems
+func CleanFilename(name string) string {
+    if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+        name = name[i+1:]
+    }
+    name = strings.Map(func(r rune) rune {
+        if r < 0x20 || r == 0x7f || r == '"' {
+            return -1
+        }
+        return r
+    }, name)
+    return strings.TrimSpace(name)
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// Entity types recorded in the audit log
+const (
+    EntityTransaction = "transaction"
+    EntityCategory    = "category"
+    EntityPayee       = "payee"
+)
+
+// Audit actions
+const (
+    ActionCreate  = "create"
+    ActionUpdate  = "update"
+    ActionDelete  = "delete"
+    ActionRestore = "restore"
+    ActionPurge   = "purge"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx so helpers can run
+// inside or outside a database transaction
+type queryer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    Query(query string, args ...interface{}) (*sql.Rows, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// AuditEntry is one append-only record of a change to a transaction or category
+type AuditEntry struct {
+    ID         int64           `json:"id"`
+    EntityType string          `json:"entity_type"`
+    EntityID   int             `json:"entity_id"`
+    Action     string          `json:"action"`
+    Before     json.RawMessage `json:"before,omitempty"`
+    After      json.RawMessage `json:"after,omitempty"`
+    Actor      string          `json:"actor"`
+    CreatedAt  time.Time       `json:"created_at"`
+}
+
+// FieldChange describes how one field differs between Before and After
+type FieldChange struct {
+    Field string
+    From  string
+    To    string
+}
+
+// auditFields lists the snapshot keys shown in the change log, in order
+var auditFields = []struct {
+    key   string
+    label string
+}{
+    {"amount", "Amount"},
+    {"currency", "Currency"},
+    {"category", "Category"},
+    {"account", "Account"},
+    {"payee", "Payee"},
+    {"transaction_date", "Date"},
+    {"description", "Description"},
+    {"tags", "Tags"},
+    {"attachment_added", "Attachment added"},
+    {"attachment_removed", "Attachment removed"},
+    {"name", "Name"},
+    {"type", "Type"},
+}
+
+// recordAudit appends an entry to the audit log. before and after are
+// snapshots of the record and may be nil for creates and deletes.
+func recordAudit(q queryer, entityType string, entityID int, action string, before, after interface{}, actor string) error {
+    beforeJSON, err := snapshotJSON(before)
+    if err != nil {
+        return err
+    }
+    afterJSON, err := snapshotJSON(after)
+    if err != nil {
+        return err
+    }
+
+    stmt := `
+        INSERT INTO audit_log (entity_type, entity_id, action, before_data, after_data, actor)
+        VALUES ($1, $2, $3, $4, $5, $6)`
+
+    _, err = q.Exec(stmt, entityType, entityID, action, beforeJSON, afterJSON, actor)
+    return err
+}
+
+func snapshotJSON(snapshot interface{}) (interface{}, error) {
+    if snapshot == nil {
+        return nil, nil
+    }
+    data, err := json.Marshal(snapshot)
+    if err != nil {
+        return nil, fmt.Errorf("error encoding audit snapshot: %v", err)
+    }
+    return string(data), nil
+}
+
+// GetAuditLog retrieves the history of a record, newest first
+func GetAuditLog(entityType string, entityID int) ([]AuditEntry, error) {
+    stmt := `
+        SELECT id, entity_type, entity_id, action, before_data, after_data, actor, created_at
+        FROM audit_log
+        WHERE entity_type = $1 AND entity_id = $2
+        ORDER BY created_at DESC, id DESC`
+
+    rows, err := database.DB.Query(stmt, entityType, entityID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var entries []AuditEntry
+
+    for rows.Next() {
+        var entry AuditEntry
+        var before, after []byte
+        if err := rows.Scan(
+            &entry.ID,
+            &entry.EntityType,
+            &entry.EntityID,
+            &entry.Action,
+            &before,
+            &after,
+            &entry.Actor,
+            &entry.CreatedAt,
+        ); err != nil {
+            return nil, err
+        }
+        entry.Before = before
+        entry.After = after
+        entries = append(entries, entry)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return entries, nil
+}
+
+// Changes lists the fields that differ between the before and after
+// snapshots. For creates every field is listed with an empty From, for
+// deletes with an empty To.
+func (e AuditEntry) Changes() []FieldChange {
+    return diffSnapshots(e.Before, e.After)
+}
+
+// diffSnapshots compares two JSON snapshots field by field
+func diffSnapshots(beforeJSON, afterJSON []byte) []FieldChange {
+    before := map[string]interface{}{}
+    after := map[string]interface{}{}
+    json.Unmarshal(beforeJSON, &before)
+    json.Unmarshal(afterJSON, &after)
+
+    var changes []FieldChange
+    for _, field := range auditFields {
+        from, hadFrom := before[field.key]
+        to, hasTo := after[field.key]
+        if !hadFrom && !hasTo {
+            continue
+        }
+
+        fromText := formatAuditValue(field.key, from)
+        toText := formatAuditValue(field.key, to)
+        if fromText == toText {
+            continue
+        }
+        changes = append(changes, FieldChange{Field: field.label, From: fromText, To: toText})
+    }
+    return changes
+}
+
+func formatAuditValue(key string, value interface{}) string {
+    switch v := value.(type) {
+    case nil:
+        return ""
+    case float64:
+        if key == "amount" {
+            return strconv.FormatFloat(v, 'f', 2, 64)
+        }
+        return strconv.FormatFloat(v, 'f', -1, 64)
+    case string:
+        return v
+    case []interface{}:
+        parts := make([]string, len(v))
+        for i, item := range v {
+            parts[i] = formatAuditValue(key, item)
+        }
+        return strings.Join(parts, ", ")
+    default:
+        return fmt.Sprint(v)
+    }
+}
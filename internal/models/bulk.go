@@ -0,0 +1,212 @@
+package models
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/lib/pq"
+
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// Bulk actions supported by ApplyBulk
+const (
+    BulkRecategorize = "recategorize"
+    BulkTag          = "tag"
+    BulkShiftDate    = "shift_date"
+    BulkDelete       = "delete"
+)
+
+// maxBulkRows caps how many transactions one bulk action may touch
+const maxBulkRows = 500
+
+// BulkOperation describes a change applied to many transactions at once
+type BulkOperation struct {
+    Action     string
+    CategoryID int    // for BulkRecategorize
+    Tag        string // for BulkTag, may list several tags separated by commas
+    Days       int    // for BulkShiftDate; negative moves dates back
+}
+
+// BulkRowResult reports what happened to one transaction
+type BulkRowResult struct {
+    Transaction Transaction
+    Changes     []FieldChange
+    Errors      map[string]string
+}
+
+// BulkResult summarises a bulk action. If any row failed validation nothing
+// was saved and Applied is false.
+type BulkResult struct {
+    Operation BulkOperation
+    Rows      []BulkRowResult
+    Applied   bool
+}
+
+// Changed counts the rows whose values actually changed
+func (r BulkResult) Changed() int {
+    count := 0
+    for _, row := range r.Rows {
+        if len(row.Changes) > 0 {
+            count++
+        }
+    }
+    return count
+}
+
+// Failed counts the rows that did not pass validation
+func (r BulkResult) Failed() int {
+    count := 0
+    for _, row := range r.Rows {
+        if len(row.Errors) > 0 {
+            count++
+        }
+    }
+    return count
+}
+
+// errBulkInvalid rolls back a bulk action when a row fails validation
+var errBulkInvalid = errors.New("bulk action failed validation")
+
+// ValidateBulkOperation checks the parameters of a bulk action
+func ValidateBulkOperation(v *validator.Validator, op BulkOperation, ids []int) {
+    v.Check(len(ids) > 0, "ids", "Select at least one transaction")
+    v.Check(len(ids) <= maxBulkRows, "ids", fmt.Sprintf("At most %d transactions can be changed at once", maxBulkRows))
+
+    switch op.Action {
+    case BulkRecategorize:
+        v.Check(op.CategoryID > 0, "category_id", "Please select a valid category")
+    case BulkTag:
+        v.Check(len(ParseTags(op.Tag)) > 0, "tag", "Tag is required")
+    case BulkShiftDate:
+        v.Check(op.Days != 0, "days", "Enter a number of days other than zero")
+        v.Check(op.Days >= -3660 && op.Days <= 3660, "days", "Dates can be shifted by at most ten years")
+    case BulkDelete:
+    default:
+        v.AddError("action", "Please choose an action")
+    }
+}
+
+// ApplyBulk applies op to every transaction in ids inside one database
+// transaction. Each changed row is checked with ValidateTransaction and
+// recorded in the audit log; a single invalid row rolls back the whole batch.
+func ApplyBulk(ids []int, op BulkOperation, actor string) (*BulkResult, error) {
+    result := &BulkResult{Operation: op}
+
+    err := withTx(func(tx *sql.Tx) error {
+        invalid := false
+
+        for _, id := range ids {
+            before, err := getTransactionByID(tx, id, true)
+            if errors.Is(err, sql.ErrNoRows) {
+                result.Rows = append(result.Rows, BulkRowResult{
+                    Transaction: Transaction{ID: id},
+                    Errors:      map[string]string{"id": "Transaction no longer exists"},
+                })
+                invalid = true
+                continue
+            }
+            if err != nil {
+                return err
+            }
+
+            row, err := applyBulkRow(tx, before, op, actor)
+            if err != nil {
+                return err
+            }
+            if len(row.Errors) > 0 {
+                invalid = true
+            }
+            result.Rows = append(result.Rows, row)
+        }
+
+        if invalid {
+            return errBulkInvalid
+        }
+        return nil
+    })
+
+    if errors.Is(err, errBulkInvalid) {
+        return result, nil
+    }
+    if err != nil {
+        return nil, dbError(err)
+    }
+
+    result.Applied = true
+    return result, nil
+}
+
+func applyBulkRow(tx *sql.Tx, before Transaction, op BulkOperation, actor string) (BulkRowResult, error) {
+    if op.Action == BulkDelete {
+        stmt := `UPDATE transactions SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1`
+        if _, err := tx.Exec(stmt, before.ID); err != nil {
+            return BulkRowResult{}, err
+        }
+        if err := recordAudit(tx, EntityTransaction, before.ID, ActionDelete, before.auditSnapshot(), nil, actor); err != nil {
+            return BulkRowResult{}, err
+        }
+        return BulkRowResult{Transaction: before, Changes: snapshotChanges(before.auditSnapshot(), nil)}, nil
+    }
+
+    after := before
+    after.Tags = append([]string(nil), before.Tags...)
+
+    switch op.Action {
+    case BulkRecategorize:
+        after.CategoryID = op.CategoryID
+    case BulkTag:
+        after.Tags = ParseTags(before.TagString() + "," + op.Tag)
+    case BulkShiftDate:
+        after.TransactionDate = before.TransactionDate.AddDate(0, 0, op.Days)
+    }
+
+    return saveBulkRow(tx, before, after, actor)
+}
+
+// saveBulkRow validates after with ValidateTransaction and, if it passes,
+// saves its category, tags, date and description and records the change
+func saveBulkRow(tx *sql.Tx, before, after Transaction, actor string) (BulkRowResult, error) {
+    v := validator.NewValidator()
+    ValidateTransaction(v, &after)
+    if !v.ValidData() {
+        return BulkRowResult{Transaction: after, Errors: v.Errors}, nil
+    }
+
+    stmt := `
+        UPDATE transactions
+        SET category_id = $1, tags = $2, transaction_date = $3, description = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5`
+    if _, err := tx.Exec(stmt, after.CategoryID, pq.Array(after.tagList()), after.TransactionDate, after.Description, after.ID); err != nil {
+        return BulkRowResult{}, err
+    }
+
+    // Reload so the category name in the snapshot is current
+    saved, err := getTransactionByID(tx, after.ID, false)
+    if err != nil {
+        return BulkRowResult{}, err
+    }
+
+    changes := snapshotChanges(before.auditSnapshot(), saved.auditSnapshot())
+    if len(changes) > 0 {
+        if err := recordAudit(tx, EntityTransaction, saved.ID, ActionUpdate, before.auditSnapshot(), saved.auditSnapshot(), actor); err != nil {
+            return BulkRowResult{}, err
+        }
+    }
+
+    return BulkRowResult{Transaction: saved, Changes: changes}, nil
+}
+
+// snapshotChanges diffs two audit snapshots
+func snapshotChanges(before, after map[string]interface{}) []FieldChange {
+    var beforeJSON, afterJSON []byte
+    if before != nil {
+        beforeJSON, _ = json.Marshal(before)
+    }
+    if after != nil {
+        afterJSON, _ = json.Marshal(after)
+    }
+    return diffSnapshots(beforeJSON, afterJSON)
+}
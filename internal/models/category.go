@@ -1,6 +1,7 @@
 package models
 
 import (
+    "database/sql"
     "time"
     
     "github.com/bryan/finance-tracker/internal/database"
@@ -15,14 +16,28 @@ type Category struct {
     UpdatedAt time.Time `json:"updated_at"`
 }
 
-// Create adds a new category to the database
-func (c *Category) Create() error {
+// Create adds a new category to the database and records it in the audit log
+func (c *Category) Create(actor string) error {
     stmt := `
         INSERT INTO categories (name, type) 
         VALUES ($1, $2)
         RETURNING id, created_at, updated_at`
 
-    return database.DB.QueryRow(stmt, c.Name, c.Type).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+    err := withTx(func(tx *sql.Tx) error {
+        if err := tx.QueryRow(stmt, c.Name, c.Type).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+            return err
+        }
+        return recordAudit(tx, EntityCategory, c.ID, ActionCreate, nil, c.auditSnapshot(), actor)
+    })
+    return dbError(err)
+}
+
+// auditSnapshot returns the fields of c recorded in the audit log
+func (c Category) auditSnapshot() map[string]interface{} {
+    return map[string]interface{}{
+        "name": c.Name,
+        "type": c.Type,
+    }
 }
 
 // GetAllCategories retrieves all categories from the database
@@ -98,7 +113,7 @@ func GetCategoryByID(id int) (Category, error) {
     err := database.DB.QueryRow(stmt, id).Scan(
         &category.ID, &category.Name, &category.Type, &category.CreatedAt, &category.UpdatedAt)
     
-    return category, err
+    return category, dbError(err)
 }
 
 // ValidateCategory validates category data
@@ -0,0 +1,84 @@
+package models
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// relativeDatePattern matches dates such as -30d, -2w, -3m or +1y
+var relativeDatePattern = regexp.MustCompile(`^([+-]\d{1,4})([dwmy])$`)
+
+// DateRangePreset returns the first and last day of a named range: one of
+// this_period, last_period, this_month, last_month, ytd, last_12_months or
+// last_7_days. Periods follow the pay cycle in the preferences. today should
+// be the current date in the user's time zone.
+func DateRangePreset(preset string, today time.Time) (time.Time, time.Time, error) {
+    today = dateOnly(today)
+    startOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+    switch preset {
+    case "this_period":
+        period, _ := NewPeriod("budget", today)
+        return period.Start, period.End, nil
+    case "last_period":
+        period, _ := NewPeriod("budget", today)
+        previous := period.Previous()
+        return previous.Start, previous.End, nil
+    case "this_month":
+        return startOfMonth, startOfMonth.AddDate(0, 1, -1), nil
+    case "last_month":
+        return startOfMonth.AddDate(0, -1, 0), startOfMonth.AddDate(0, 0, -1), nil
+    case "ytd":
+        return time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC), today, nil
+    case "last_12_months":
+        return today.AddDate(-1, 0, 1), today, nil
+    case "last_7_days":
+        return today.AddDate(0, 0, -6), today, nil
+    }
+    return time.Time{}, time.Time{}, &ValidationError{Field: "range", Message: "Please choose a valid date range"}
+}
+
+// ParseDate reads a date written as 2024-01-31, as "today", or relative to
+// today in days, weeks, months or years, such as -30d or +1m
+func ParseDate(value string, today time.Time) (time.Time, error) {
+    value = strings.ToLower(strings.TrimSpace(value))
+    today = dateOnly(today)
+
+    if value == "today" {
+        return today, nil
+    }
+
+    if match := relativeDatePattern.FindStringSubmatch(value); match != nil {
+        n, _ := strconv.Atoi(match[1])
+        switch match[2] {
+        case "d":
+            return today.AddDate(0, 0, n), nil
+        case "w":
+            return today.AddDate(0, 0, 7*n), nil
+        case "m":
+            return addMonths(today, n), nil
+        default:
+            return addMonths(today, 12*n), nil
+        }
+    }
+
+    date, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        return time.Time{}, &ValidationError{Message: "Dates must look like 2024-01-31, today, or -30d"}
+    }
+    return date, nil
+}
+
+// addMonths moves t by n calendar months, keeping its day of the month but
+// stopping at the end of shorter months
+func addMonths(t time.Time, n int) time.Time {
+    first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+    lastDay := first.AddDate(0, 1, -1).Day()
+    day := t.Day()
+    if day > lastDay {
+        day = lastDay
+    }
+    return first.AddDate(0, 0, day-1)
+}
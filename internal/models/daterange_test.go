@@ -0,0 +1,100 @@
+package models
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+// presetToday is the fixed date relative ranges and dates are resolved against
+var presetToday = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+func TestDateRangePreset(t *testing.T) {
+    // Budget periods start on the 25th for the period presets
+    prefs := DefaultPreferences
+    prefs.PeriodStartDay = 25
+    if err := setPreferences(prefs); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { setPreferences(DefaultPreferences) })
+
+    tests := []struct {
+        preset string
+        today  time.Time
+        want   string
+    }{
+        {"this_period", presetToday, "2024-03-25 to 2024-04-24"},
+        {"this_period", presetToday.AddDate(0, 0, -21), "2024-02-25 to 2024-03-24"}, // 10 March
+        {"last_period", presetToday, "2024-02-25 to 2024-03-24"},
+        {"this_month", presetToday, "2024-03-01 to 2024-03-31"},
+        {"last_month", presetToday, "2024-02-01 to 2024-02-29"},
+        {"last_month", presetToday.AddDate(0, -2, -16), "2023-12-01 to 2023-12-31"}, // 15 January
+        {"ytd", presetToday, "2024-01-01 to 2024-03-31"},
+        {"last_12_months", presetToday, "2023-04-01 to 2024-03-31"},
+        {"last_7_days", presetToday, "2024-03-25 to 2024-03-31"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.preset+" on "+tt.today.Format("2006-01-02"), func(t *testing.T) {
+            start, end, err := DateRangePreset(tt.preset, tt.today)
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got := start.Format("2006-01-02") + " to " + end.Format("2006-01-02"); got != tt.want {
+                t.Errorf("range = %s, want %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDateRangePresetInvalid(t *testing.T) {
+    for _, preset := range []string{"", "next_month", "THIS_MONTH"} {
+        _, _, err := DateRangePreset(preset, presetToday)
+        var verr *ValidationError
+        if !errors.As(err, &verr) || verr.Field != "range" {
+            t.Errorf("DateRangePreset(%q) error = %v, want a range ValidationError", preset, err)
+        }
+    }
+}
+
+func TestParseDate(t *testing.T) {
+    tests := []struct {
+        value   string
+        want    string
+        wantErr bool
+    }{
+        {"2024-01-05", "2024-01-05", false},
+        {"today", "2024-03-31", false},
+        {" Today ", "2024-03-31", false},
+        {"-30d", "2024-03-01", false},
+        {"+2w", "2024-04-14", false},
+        {"-1m", "2024-02-29", false}, // Stops at the end of February
+        {" -3M ", "2023-12-31", false},
+        {"+1m", "2024-04-30", false},
+        {"-1y", "2023-03-31", false},
+        {"30d", "", true},
+        {"-1q", "", true},
+        {"-12345d", "", true},
+        {"2024-02-30", "", true},
+        {"31/03/2024", "", true},
+        {"", "", true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.value, func(t *testing.T) {
+            date, err := ParseDate(tt.value, presetToday)
+            if tt.wantErr {
+                if err == nil {
+                    t.Errorf("ParseDate(%q) = %s, want an error", tt.value, date.Format("2006-01-02"))
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got := date.Format("2006-01-02"); got != tt.want {
+                t.Errorf("ParseDate(%q) = %s, want %s", tt.value, got, tt.want)
+            }
+        })
+    }
+}
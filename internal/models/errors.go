@@ -0,0 +1,71 @@
+package models
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+
+    "github.com/lib/pq"
+)
+
+var (
+    // ErrNotFound is returned when the requested record does not exist
+    ErrNotFound = errors.New("record not found")
+
+    // ErrConflict is returned when a change clashes with an existing record,
+    // for example a duplicate value in a unique column
+    ErrConflict = errors.New("record conflicts with an existing record")
+
+    // ErrForeignKey is returned when a change references a missing record or
+    // removes a record that other rows still depend on
+    ErrForeignKey = errors.New("record is referenced by or references another record")
+
+    // ErrValidation is matched by every *ValidationError
+    ErrValidation = errors.New("validation failed")
+)
+
+// ValidationError reports input that could not be accepted. Message is safe
+// to show to the user.
+type ValidationError struct {
+    Field   string
+    Message string
+}
+
+func (e *ValidationError) Error() string {
+    if e.Field == "" {
+        return e.Message
+    }
+    return e.Field + ": " + e.Message
+}
+
+// Is lets errors.Is(err, ErrValidation) match any ValidationError
+func (e *ValidationError) Is(target error) bool {
+    return target == ErrValidation
+}
+
+// dbError translates driver errors into the sentinel errors above. The
+// original error is kept in the chain for logging but never shown to users.
+func dbError(err error) error {
+    if err == nil {
+        return nil
+    }
+
+    if errors.Is(err, sql.ErrNoRows) {
+        return ErrNotFound
+    }
+
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) {
+        switch pqErr.Code.Name() {
+        case "foreign_key_violation":
+            return fmt.Errorf("%w: %w", ErrForeignKey, err)
+        case "unique_violation", "exclusion_violation":
+            return fmt.Errorf("%w: %w", ErrConflict, err)
+        case "check_violation", "not_null_violation", "string_data_right_truncation",
+            "numeric_value_out_of_range", "invalid_text_representation":
+            return fmt.Errorf("%w: %w", &ValidationError{Message: "The submitted values are not valid"}, err)
+        }
+    }
+
+    return err
+}
@@ -0,0 +1,326 @@
+package models
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/csv"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// baseAmount is a transaction's amount converted into the base currency at
+// the rate for its date, or NULL when no rate is known. It expects the
+// transactions table as t.
+const baseAmount = `base_amount(t.amount, t.currency, t.transaction_date)`
+
+// ecbBase is the currency the European Central Bank quotes its rates against
+const ecbBase = "EUR"
+
+// ecbRetired lists currencies the ECB history files still have columns for
+// but that are no longer in use. Their rates are skipped.
+var ecbRetired = map[string]bool{
+    "CYP": true, "EEK": true, "HRK": true, "LTL": true, "LVL": true,
+    "MTL": true, "ROL": true, "SIT": true, "SKK": true, "TRL": true,
+}
+
+// ExchangeRate is one day's rate between two currencies: one unit of Base
+// buys Rate units of Quote. A rate applies from its date until the next one
+// for the same pair, and is also used inverted and to cross two currencies
+// quoted against the same base.
+type ExchangeRate struct {
+    ID        int       `json:"id"`
+    Base      string    `json:"base"`
+    Quote     string    `json:"quote"`
+    Date      time.Time `json:"date"`
+    Rate      float64   `json:"rate"`
+    Source    string    `json:"source"` // manual or ecb
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Save records the rate, replacing any earlier one for the same pair and day
+func (r *ExchangeRate) Save() error {
+    stmt := `
+        INSERT INTO exchange_rates (base_currency, quote_currency, rate_date, rate, source)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (base_currency, quote_currency, rate_date)
+        DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, updated_at = CURRENT_TIMESTAMP
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, r.Base, r.Quote, r.Date, r.Rate, r.Source).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+    return dbError(err)
+}
+
+// Delete removes a rate
+func (r *ExchangeRate) Delete() error {
+    result, err := database.DB.Exec(`DELETE FROM exchange_rates WHERE id = $1`, r.ID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// GetExchangeRates retrieves the most recent rates, at most limit of them
+func GetExchangeRates(limit int) ([]ExchangeRate, error) {
+    stmt := `
+        SELECT id, base_currency, quote_currency, rate_date, rate, source, created_at, updated_at
+        FROM exchange_rates
+        ORDER BY rate_date DESC, base_currency, quote_currency
+        LIMIT $1`
+
+    rows, err := database.DB.Query(stmt, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var rates []ExchangeRate
+
+    for rows.Next() {
+        var r ExchangeRate
+        if err := rows.Scan(&r.ID, &r.Base, &r.Quote, &r.Date, &r.Rate, &r.Source, &r.CreatedAt, &r.UpdatedAt); err != nil {
+            return nil, err
+        }
+        rates = append(rates, r)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return rates, nil
+}
+
+// CountExchangeRates returns how many rates are stored
+func CountExchangeRates() (int, error) {
+    var count int
+    err := database.DB.QueryRow(`SELECT COUNT(*) FROM exchange_rates`).Scan(&count)
+    return count, err
+}
+
+// ImportExchangeRates saves rates in one statement, replacing any already
+// stored for the same pair and day. It returns how many were saved.
+func ImportExchangeRates(rates []ExchangeRate) (int, error) {
+    type key struct {
+        base, quote string
+        date        time.Time
+    }
+
+    // A file may list the same day twice; the last one wins, as it would
+    // if the rows were saved one at a time
+    index := make(map[key]int, len(rates))
+    var bases, quotes, dates, sources []string
+    var values []float64
+    for _, r := range rates {
+        k := key{r.Base, r.Quote, dateOnly(r.Date)}
+        if i, ok := index[k]; ok {
+            values[i], sources[i] = r.Rate, r.Source
+            continue
+        }
+        index[k] = len(values)
+        bases = append(bases, r.Base)
+        quotes = append(quotes, r.Quote)
+        dates = append(dates, r.Date.Format("2006-01-02"))
+        values = append(values, r.Rate)
+        sources = append(sources, r.Source)
+    }
+    if len(values) == 0 {
+        return 0, nil
+    }
+
+    stmt := `
+        INSERT INTO exchange_rates (base_currency, quote_currency, rate_date, rate, source)
+        SELECT * FROM unnest($1::char(3)[], $2::char(3)[], $3::date[], $4::numeric[], $5::varchar[])
+        ON CONFLICT (base_currency, quote_currency, rate_date)
+        DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, updated_at = CURRENT_TIMESTAMP`
+
+    _, err := database.DB.Exec(stmt, pq.Array(bases), pq.Array(quotes), pq.Array(dates), pq.Array(values), pq.Array(sources))
+    if err != nil {
+        return 0, dbError(err)
+    }
+    return len(values), nil
+}
+
+// dailyRates returns the rate from currency into the base currency for every
+// day from start to end. Days without a known rate are left out.
+func dailyRates(currency string, start, end time.Time) (map[time.Time]float64, error) {
+    stmt := `
+        SELECT d::date, exchange_rate($1, (SELECT currency FROM preferences WHERE id = 1), d::date)
+        FROM generate_series($2::date, $3::date, interval '1 day') AS d`
+
+    rows, err := database.DB.Query(stmt, currency, start, end)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    rates := make(map[time.Time]float64)
+    for rows.Next() {
+        var day time.Time
+        var rate sql.NullFloat64
+        if err := rows.Scan(&day, &rate); err != nil {
+            return nil, err
+        }
+        if rate.Valid {
+            rates[dateOnly(day)] = rate.Float64
+        }
+    }
+
+    return rates, rows.Err()
+}
+
+// ParseECBRates reads the euro reference rates published by the European
+// Central Bank, either as XML (eurofxref-daily.xml, eurofxref-hist.xml) or
+// as CSV (eurofxref.csv, eurofxref-hist.csv). Every rate has EUR as its
+// base. A file that cannot be read is reported as a *ValidationError.
+func ParseECBRates(data []byte) ([]ExchangeRate, error) {
+    var rates []ExchangeRate
+    var err error
+    if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+        rates, err = parseECBXML(data)
+    } else {
+        rates, err = parseECBCSV(data)
+    }
+    if err != nil {
+        return nil, &ValidationError{Field: "file", Message: err.Error()}
+    }
+    if len(rates) == 0 {
+        return nil, &ValidationError{Field: "file", Message: "The file does not contain any exchange rates"}
+    }
+    return rates, nil
+}
+
+// ecbEnvelope is the part of the ECB XML feed holding the rates:
+// <Cube><Cube time="2024-01-19"><Cube currency="USD" rate="1.0887"/>...
+type ecbEnvelope struct {
+    Days []struct {
+        Time  string `xml:"time,attr"`
+        Rates []struct {
+            Currency string `xml:"currency,attr"`
+            Rate     string `xml:"rate,attr"`
+        } `xml:"Cube"`
+    } `xml:"Cube>Cube"`
+}
+
+func parseECBXML(data []byte) ([]ExchangeRate, error) {
+    var envelope ecbEnvelope
+    if err := xml.Unmarshal(data, &envelope); err != nil {
+        return nil, fmt.Errorf("The XML file could not be read: %v", err)
+    }
+
+    var rates []ExchangeRate
+    for _, day := range envelope.Days {
+        date, err := time.Parse("2006-01-02", day.Time)
+        if err != nil {
+            return nil, fmt.Errorf("Invalid date %q", day.Time)
+        }
+        for _, r := range day.Rates {
+            if ecbRetired[ParseCurrency(r.Currency)] {
+                continue
+            }
+            rate, err := ecbRate(date, r.Currency, r.Rate)
+            if err != nil {
+                return nil, err
+            }
+            rates = append(rates, rate)
+        }
+    }
+    return rates, nil
+}
+
+// parseECBCSV reads a header of Date followed by currency codes, then one
+// row per day. The ECB pads cells with spaces, ends rows with a comma and
+// writes N/A for currencies it no longer quotes, including the retired ones
+// in the history file.
+func parseECBCSV(data []byte) ([]ExchangeRate, error) {
+    reader := csv.NewReader(bytes.NewReader(data))
+    reader.FieldsPerRecord = -1
+    reader.TrimLeadingSpace = true
+
+    header, err := reader.Read()
+    if err == io.EOF {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("The CSV file could not be read: %v", err)
+    }
+    if len(header) < 2 || !strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(header[0], "\ufeff")), "date") {
+        return nil, fmt.Errorf("The first column must be Date, followed by currency codes")
+    }
+
+    var rates []ExchangeRate
+    for line := 2; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("The CSV file could not be read: %v", err)
+        }
+
+        value := strings.TrimSpace(record[0])
+        if value == "" {
+            continue
+        }
+        date, err := time.Parse("2006-01-02", value)
+        if err != nil {
+            // The daily file writes dates such as 19 January 2024
+            if date, err = time.Parse("2 January 2006", value); err != nil {
+                return nil, fmt.Errorf("Line %d: Invalid date %q", line, value)
+            }
+        }
+
+        for i := 1; i < len(record) && i < len(header); i++ {
+            code, value := strings.TrimSpace(header[i]), strings.TrimSpace(record[i])
+            if code == "" || value == "" || value == "N/A" || ecbRetired[ParseCurrency(code)] {
+                continue
+            }
+            rate, err := ecbRate(date, code, value)
+            if err != nil {
+                return nil, fmt.Errorf("Line %d: %v", line, err)
+            }
+            rates = append(rates, rate)
+        }
+    }
+    return rates, nil
+}
+
+// ecbRate builds an imported rate from EUR to code
+func ecbRate(date time.Time, code, value string) (ExchangeRate, error) {
+    code = ParseCurrency(code)
+    if !locale.IsCurrency(code) || code == ecbBase {
+        return ExchangeRate{}, fmt.Errorf("Unknown currency %q", code)
+    }
+    rate, err := strconv.ParseFloat(value, 64)
+    if err != nil || rate <= 0 || rate >= 1e10 {
+        return ExchangeRate{}, fmt.Errorf("Invalid %s rate %q", code, value)
+    }
+    return ExchangeRate{Base: ecbBase, Quote: code, Date: date, Rate: rate, Source: "ecb"}, nil
+}
+
+// ValidateExchangeRate validates rate data
+func ValidateExchangeRate(v *validator.Validator, r *ExchangeRate) {
+    v.Check(locale.IsCurrency(r.Base), "base", "Please enter a currency code such as EUR")
+    v.Check(locale.IsCurrency(r.Quote), "quote", "Please enter a currency code such as USD")
+    v.Check(r.Base != r.Quote, "quote", "The two currencies must be different")
+    v.Check(!r.Date.IsZero(), "date", "Date is required")
+    v.Check(r.Rate > 0 && r.Rate < 1e10, "rate", "Rate must be a positive number")
+}
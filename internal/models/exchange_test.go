@@ -0,0 +1,94 @@
+package models
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+const ecbDailyXML = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+    <gesmes:subject>Reference rates</gesmes:subject>
+    <Cube>
+        <Cube time="2024-01-19">
+            <Cube currency="USD" rate="1.0887"/>
+            <Cube currency="JPY" rate="161.17"/>
+            <Cube currency="SIT" rate="239.64"/>
+        </Cube>
+        <Cube time="2024-01-18">
+            <Cube currency="USD" rate="1.0875"/>
+        </Cube>
+    </Cube>
+</gesmes:Envelope>`
+
+// ecbRates lists parsed rates as "date quote rate", checking each is an ECB
+// rate from EUR
+func ecbRates(t *testing.T, rates []ExchangeRate) string {
+    t.Helper()
+    var lines []string
+    for _, r := range rates {
+        if r.Base != "EUR" || r.Source != "ecb" {
+            t.Errorf("rate %+v, want base EUR and source ecb", r)
+        }
+        lines = append(lines, fmt.Sprintf("%s %s %g", r.Date.Format("2006-01-02"), r.Quote, r.Rate))
+    }
+    return strings.Join(lines, ", ")
+}
+
+func TestParseECBRates(t *testing.T) {
+    tests := []struct {
+        name string
+        data string
+        want string
+    }{
+        {"XML skips retired currencies", ecbDailyXML, "2024-01-19 USD 1.0887, 2024-01-19 JPY 161.17, 2024-01-18 USD 1.0875"},
+        {"daily CSV", "Date, USD, JPY, \n19 January 2024, 1.0887, 161.17, \n", "2024-01-19 USD 1.0887, 2024-01-19 JPY 161.17"},
+        {"history CSV skips N/A and retired currencies", "\ufeffDate,USD,CYP,\n2024-01-19,1.0887,N/A,\n2008-01-02,1.4683,0.585274,\n", "2024-01-19 USD 1.0887, 2008-01-02 USD 1.4683"},
+        {"CSV with a blank row", "Date,usd\n\n2024-01-19,1.0887\n", "2024-01-19 USD 1.0887"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rates, err := ParseECBRates([]byte(tt.data))
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got := ecbRates(t, rates); got != tt.want {
+                t.Errorf("rates = %s, want %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseECBRatesInvalid(t *testing.T) {
+    tests := []struct {
+        name        string
+        data        string
+        wantMessage string
+    }{
+        {"empty", "", "does not contain any exchange rates"},
+        {"header only", "Date,USD\n", "does not contain any exchange rates"},
+        {"wrong header", "Day,USD\n2024-01-19,1.0887\n", "first column must be Date"},
+        {"bad CSV date", "Date,USD\n19/01/2024,1.0887\n", "Line 2: Invalid date"},
+        {"unknown CSV currency", "Date,XYZ\n2024-01-19,1.5\n", "Line 2: Unknown currency \"XYZ\""},
+        {"EUR against itself", "Date,EUR\n2024-01-19,1\n", "Unknown currency \"EUR\""},
+        {"negative CSV rate", "Date,USD\n2024-01-19,-1.0887\n", "Invalid USD rate"},
+        {"malformed XML", "<Cube><Cube time=\"2024-01-19\">", "XML file could not be read"},
+        {"bad XML date", `<Envelope><Cube><Cube time="19 January 2024"><Cube currency="USD" rate="1.0887"/></Cube></Cube></Envelope>`, "Invalid date"},
+        {"bad XML rate", `<Envelope><Cube><Cube time="2024-01-19"><Cube currency="USD" rate="abc"/></Cube></Cube></Envelope>`, "Invalid USD rate"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rates, err := ParseECBRates([]byte(tt.data))
+            var verr *ValidationError
+            if !errors.As(err, &verr) || verr.Field != "file" {
+                t.Fatalf("ParseECBRates = %v, %v, want a file ValidationError", rates, err)
+            }
+            if !strings.Contains(verr.Message, tt.wantMessage) {
+                t.Errorf("message = %q, want it to contain %q", verr.Message, tt.wantMessage)
+            }
+        })
+    }
+}
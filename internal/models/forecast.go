@@ -0,0 +1,200 @@
+package models
+
+import (
+    "database/sql"
+    "math"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// Forecast limits
+const (
+    DefaultForecastDays = 60
+    MaxForecastDays     = 365
+
+    // baselineDays is how much history the variable spending baseline uses
+    baselineDays = 84
+
+    // recurringTolerance is how far a past expense's amount may differ from
+    // a recurring item in the same category and still count as that item
+    recurringTolerance = 0.1
+)
+
+// ForecastDay is the projected balance at the end of one day
+type ForecastDay struct {
+    Date      time.Time `json:"date"`
+    Scheduled float64   `json:"scheduled"` // Net amount of the recurring items due
+    Items     []string  `json:"items"`     // Names of the recurring items due
+    Baseline  float64   `json:"baseline"`  // Expected variable spending, negative
+    Balance   float64   `json:"balance"`
+    AtRisk    bool      `json:"at_risk"` // Balance below the threshold
+}
+
+// Forecast projects an account's daily balance from its recurring items
+// plus a baseline of the variable spending seen in recent history
+type Forecast struct {
+    Account       Account       `json:"account"`
+    StartDate     time.Time     `json:"start_date"`
+    StartBalance  float64       `json:"start_balance"`
+    Threshold     float64       `json:"threshold"`
+    Days          []ForecastDay `json:"days"`
+    Low           ForecastDay   `json:"low"`            // Day with the lowest balance
+    RiskDates     []time.Time   `json:"risk_dates"`     // Days below the threshold
+    DailyBaseline float64       `json:"daily_baseline"` // Average variable spending per day
+    HistoryDays   int           `json:"history_days"`   // Days of history behind the baseline
+}
+
+// GetForecast projects the balance of an asset account for the days after
+// today. Days that end below threshold are reported as risk dates.
+//
+// The baseline is the account's average spending on each weekday over the
+// last twelve weeks, leaving out expenses that look like a recurring item:
+// same category and an amount within 10%. Those are already scheduled.
+func GetForecast(accountID, days int, threshold float64, now time.Time) (*Forecast, error) {
+    account, err := GetAccountByID(accountID)
+    if err != nil {
+        return nil, err
+    }
+    if account.IsLiability() {
+        return nil, &ValidationError{Field: "account_id", Message: "Forecasts are only available for asset accounts"}
+    }
+
+    today := dateOnly(now)
+    f := &Forecast{Account: account, StartDate: today, Threshold: threshold}
+
+    // The balance today already includes today's transactions
+    _, balances, err := GetNetWorth(today, today)
+    if err != nil {
+        return nil, err
+    }
+    for _, b := range balances {
+        if b.ID == accountID {
+            f.StartBalance = b.Balance
+        }
+    }
+
+    items, err := getAccountRecurringItems(accountID)
+    if err != nil {
+        return nil, err
+    }
+
+    weekdays, err := f.weekdayBaseline(today)
+    if err != nil {
+        return nil, err
+    }
+
+    // Sum each day's recurring items
+    end := today.AddDate(0, 0, days)
+    type due struct {
+        amount float64
+        names  []string
+    }
+    schedule := make(map[time.Time]*due)
+    for _, item := range items {
+        for _, date := range item.Occurrences(today.AddDate(0, 0, 1), end) {
+            date = dateOnly(date)
+            d := schedule[date]
+            if d == nil {
+                d = &due{}
+                schedule[date] = d
+            }
+            d.amount += item.SignedAmount()
+            d.names = append(d.names, item.Name)
+        }
+    }
+
+    balance := f.StartBalance
+    for i := 1; i <= days; i++ {
+        day := ForecastDay{Date: today.AddDate(0, 0, i)}
+        if d := schedule[day.Date]; d != nil {
+            day.Scheduled = d.amount
+            day.Items = d.names
+        }
+        day.Baseline = -weekdays[day.Date.Weekday()]
+
+        balance += day.Scheduled + day.Baseline
+        day.Balance = math.Round(balance*100) / 100
+        day.AtRisk = day.Balance < threshold
+
+        if len(f.Days) == 0 || day.Balance < f.Low.Balance {
+            f.Low = day
+        }
+        if day.AtRisk {
+            f.RiskDates = append(f.RiskDates, day.Date)
+        }
+        f.Days = append(f.Days, day)
+    }
+
+    return f, nil
+}
+
+// weekdayBaseline returns the average variable spending on each weekday,
+// over the baseline window or the account's history if that is shorter
+func (f *Forecast) weekdayBaseline(today time.Time) ([7]float64, error) {
+    var averages [7]float64
+
+    var first sql.NullTime
+    err := database.DB.QueryRow(`SELECT MIN(transaction_date) FROM transactions WHERE account_id = $1 AND deleted_at IS NULL`, f.Account.ID).Scan(&first)
+    if err != nil || !first.Valid {
+        return averages, err
+    }
+
+    start := today.AddDate(0, 0, -baselineDays)
+    if firstDay := dateOnly(first.Time); firstDay.After(start) {
+        start = firstDay
+    }
+    if !start.Before(today) {
+        return averages, nil
+    }
+
+    stmt := `
+        SELECT t.transaction_date, SUM(t.amount)
+        FROM transactions t
+        JOIN categories c ON t.category_id = c.id
+        WHERE t.deleted_at IS NULL AND t.account_id = $1 AND c.type = 'expense'
+            AND t.transaction_date >= $2 AND t.transaction_date < $3
+            AND NOT EXISTS (
+                SELECT 1 FROM recurring_items ri
+                WHERE ri.account_id = t.account_id AND ri.category_id = t.category_id AND ri.enabled
+                    AND t.amount BETWEEN ri.amount * (1 - $4) AND ri.amount * (1 + $4)
+            )
+        GROUP BY t.transaction_date`
+
+    rows, err := database.DB.Query(stmt, f.Account.ID, start, today, recurringTolerance)
+    if err != nil {
+        return averages, err
+    }
+    defer rows.Close()
+
+    var totals [7]float64
+    for rows.Next() {
+        var date time.Time
+        var amount float64
+        if err := rows.Scan(&date, &amount); err != nil {
+            return averages, err
+        }
+        totals[date.Weekday()] += amount
+    }
+    if err := rows.Err(); err != nil {
+        return averages, err
+    }
+
+    // Divide by how many of each weekday the window holds
+    var counts [7]int
+    for day := start; day.Before(today); day = day.AddDate(0, 0, 1) {
+        counts[day.Weekday()]++
+        f.HistoryDays++
+    }
+
+    var total float64
+    for i := range averages {
+        if counts[i] > 0 {
+            averages[i] = totals[i] / float64(counts[i])
+        }
+        total += totals[i]
+    }
+    f.DailyBaseline = total / float64(f.HistoryDays)
+
+    return averages, nil
+}
@@ -0,0 +1,345 @@
+package models
+
+import (
+    "math"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// minProjectionDays keeps one early contribution from projecting an
+// unrealistically fast savings rate
+const minProjectionDays = 30
+
+// Goal is an amount to save by an optional date. When it has a savings
+// account, everything on that account from the start date on counts: money
+// coming in, such as a transfer from another account, adds to the goal and
+// withdrawals take away from it. Transactions elsewhere can be linked by hand
+// and count in full.
+type Goal struct {
+    ID           int        `json:"id"`
+    Name         string     `json:"name"`
+    TargetAmount float64    `json:"target_amount"`
+    StartDate    time.Time  `json:"start_date"`
+    TargetDate   *time.Time `json:"target_date,omitempty"`
+    AccountID    int        `json:"account_id,omitempty"`
+    AccountName  string     `json:"account_name,omitempty"` // Used in joins
+    CreatedAt    time.Time  `json:"created_at"`
+    UpdatedAt    time.Time  `json:"updated_at"`
+
+    // Calculated from the contributions
+    Saved             float64    `json:"saved"`
+    Contributions     int        `json:"contributions"`
+    FirstContribution *time.Time `json:"first_contribution,omitempty"`
+    ProjectedDate     *time.Time `json:"projected_date,omitempty"` // Nil when reached or no rate yet
+    MonthlyNeeded     float64    `json:"monthly_needed"`           // To reach the target by TargetDate
+}
+
+// GoalContribution is a transaction counted toward a goal
+type GoalContribution struct {
+    Transaction
+    Linked     bool // linked by hand rather than through the goal's account
+    Withdrawal bool // money taken out of the goal's account
+}
+
+// SignedAmount is the amount the contribution adds to the goal, negative for
+// a withdrawal
+func (c GoalContribution) SignedAmount() float64 {
+    if c.Withdrawal {
+        return -c.Amount
+    }
+    return c.Amount
+}
+
+// Remaining is the amount still to save
+func (g Goal) Remaining() float64 {
+    return math.Max(g.TargetAmount-g.Saved, 0)
+}
+
+// Percent is the share of the target saved, capped at 100
+func (g Goal) Percent() float64 {
+    if g.TargetAmount <= 0 {
+        return 0
+    }
+    return math.Max(math.Min(g.Saved/g.TargetAmount*100, 100), 0)
+}
+
+// Reached reports whether the target amount has been saved
+func (g Goal) Reached() bool {
+    return g.Saved >= g.TargetAmount
+}
+
+// OnTrack reports whether the projected date is no later than the target date
+func (g Goal) OnTrack() bool {
+    if g.Reached() {
+        return true
+    }
+    return g.ProjectedDate != nil && g.TargetDate != nil && !g.ProjectedDate.After(*g.TargetDate)
+}
+
+// project fills in ProjectedDate and MonthlyNeeded as of now. The projection
+// assumes saving continues at the average daily rate since the first
+// contribution.
+func (g *Goal) project(now time.Time) {
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+    g.ProjectedDate = nil
+    g.MonthlyNeeded = 0
+
+    if g.Reached() {
+        return
+    }
+
+    if g.FirstContribution != nil && g.Saved > 0 {
+        days := math.Max(today.Sub(*g.FirstContribution).Hours()/24, minProjectionDays)
+        daysLeft := math.Ceil(g.Remaining() / (g.Saved / days))
+        // Beyond a century the projection is meaningless
+        if daysLeft <= 36500 {
+            projected := today.AddDate(0, 0, int(daysLeft))
+            g.ProjectedDate = &projected
+        }
+    }
+
+    if g.TargetDate != nil {
+        months := g.TargetDate.Sub(today).Hours() / 24 / 30.44
+        if months < 1 {
+            months = 1
+        }
+        g.MonthlyNeeded = g.Remaining() / months
+    }
+}
+
+// goalContributions is every live contribution as (goal_id, id, amount,
+// transaction_date). Transactions on the goal's account count with their
+// sign, income in and expenses out; hand-linked transactions elsewhere count
+// in full. A transaction on the goal's account is never counted twice.
+const goalContributions = `
+        SELECT gc.goal_id, t.id, ` + baseAmount + ` AS amount, t.transaction_date
+        FROM goal_contributions gc
+        JOIN goals g ON g.id = gc.goal_id
+        JOIN transactions t ON t.id = gc.transaction_id
+        WHERE t.deleted_at IS NULL
+          AND (g.account_id IS NULL OR t.account_id IS DISTINCT FROM g.account_id OR t.transaction_date < g.start_date)
+        UNION ALL
+        SELECT g.id, t.id, CASE WHEN c.type = 'income' THEN ` + baseAmount + ` ELSE -` + baseAmount + ` END, t.transaction_date
+        FROM goals g
+        JOIN transactions t ON t.account_id = g.account_id AND t.transaction_date >= g.start_date
+        JOIN categories c ON t.category_id = c.id
+        WHERE t.deleted_at IS NULL`
+
+const goalQuery = `
+        WITH contributions AS (` + goalContributions + `
+        )
+        SELECT g.id, g.name, g.target_amount, g.start_date, g.target_date, COALESCE(g.account_id, 0),
+            COALESCE(a.name, ''), g.created_at, g.updated_at,
+            COALESCE(SUM(ct.amount), 0), COUNT(ct.id), MIN(ct.transaction_date)
+        FROM goals g
+        LEFT JOIN accounts a ON g.account_id = a.id
+        LEFT JOIN contributions ct ON ct.goal_id = g.id`
+
+func scanGoal(row rowScanner, goal *Goal) error {
+    err := row.Scan(
+        &goal.ID,
+        &goal.Name,
+        &goal.TargetAmount,
+        &goal.StartDate,
+        &goal.TargetDate,
+        &goal.AccountID,
+        &goal.AccountName,
+        &goal.CreatedAt,
+        &goal.UpdatedAt,
+        &goal.Saved,
+        &goal.Contributions,
+        &goal.FirstContribution,
+    )
+    if err != nil {
+        return err
+    }
+
+    goal.project(Now())
+    return nil
+}
+
+// Create adds a new goal to the database
+func (g *Goal) Create() error {
+    stmt := `
+        INSERT INTO goals (name, target_amount, start_date, target_date, account_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, g.Name, g.TargetAmount, g.StartDate, g.TargetDate, nullID(g.AccountID)).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+    return dbError(err)
+}
+
+// Update saves changes to an existing goal
+func (g *Goal) Update() error {
+    stmt := `
+        UPDATE goals
+        SET name = $1, target_amount = $2, start_date = $3, target_date = $4, account_id = $5, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $6
+        RETURNING updated_at`
+
+    err := database.DB.QueryRow(stmt, g.Name, g.TargetAmount, g.StartDate, g.TargetDate, nullID(g.AccountID), g.ID).Scan(&g.UpdatedAt)
+    return dbError(err)
+}
+
+// Delete removes a goal. Its contributions stay as ordinary transactions.
+func (g *Goal) Delete() error {
+    result, err := database.DB.Exec(`DELETE FROM goals WHERE id = $1`, g.ID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// GetAllGoals retrieves every goal with its progress, nearest target first
+func GetAllGoals() ([]Goal, error) {
+    stmt := goalQuery + `
+        GROUP BY g.id, a.name
+        ORDER BY g.target_date NULLS LAST, g.name`
+
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var goals []Goal
+
+    for rows.Next() {
+        var goal Goal
+        if err := scanGoal(rows, &goal); err != nil {
+            return nil, err
+        }
+        goals = append(goals, goal)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return goals, nil
+}
+
+// GetGoalByID retrieves a goal with its progress
+func GetGoalByID(id int) (Goal, error) {
+    var goal Goal
+
+    stmt := goalQuery + `
+        WHERE g.id = $1
+        GROUP BY g.id, a.name`
+
+    err := scanGoal(database.DB.QueryRow(stmt, id), &goal)
+    return goal, dbError(err)
+}
+
+// GetGoalContributions retrieves the transactions counted toward a goal,
+// newest first
+func GetGoalContributions(goal Goal) ([]GoalContribution, error) {
+    stmt := `
+        SELECT` + transactionColumns + `,
+            EXISTS (SELECT 1 FROM goal_contributions gc WHERE gc.goal_id = $1 AND gc.transaction_id = t.id)` + transactionJoins + `
+        WHERE t.deleted_at IS NULL AND (
+            t.id IN (SELECT transaction_id FROM goal_contributions WHERE goal_id = $1)
+            OR ($2 > 0 AND t.account_id = $2 AND t.transaction_date >= $3)
+        )
+        ORDER BY t.transaction_date DESC, t.id DESC`
+
+    rows, err := database.DB.Query(stmt, goal.ID, goal.AccountID, goal.StartDate)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var contributions []GoalContribution
+
+    for rows.Next() {
+        var c GoalContribution
+        if err := scanTransaction(linkedScanner{rows, &c.Linked}, &c.Transaction); err != nil {
+            return nil, err
+        }
+        c.Withdrawal = goal.AccountID > 0 && c.AccountID == goal.AccountID &&
+            !c.TransactionDate.Before(goal.StartDate) && c.CategoryType == "expense"
+        contributions = append(contributions, c)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return contributions, nil
+}
+
+// linkedScanner appends one extra destination after the transaction columns
+type linkedScanner struct {
+    row   rowScanner
+    extra interface{}
+}
+
+func (s linkedScanner) Scan(dest ...interface{}) error {
+    return s.row.Scan(append(dest, s.extra)...)
+}
+
+// LinkContribution counts a transaction toward a goal
+func LinkContribution(goalID, transactionID int) error {
+    stmt := `
+        INSERT INTO goal_contributions (goal_id, transaction_id)
+        SELECT $1, id FROM transactions WHERE id = $2 AND deleted_at IS NULL
+        ON CONFLICT DO NOTHING`
+
+    result, err := database.DB.Exec(stmt, goalID, transactionID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    // Nothing inserted means the transaction does not exist or is in the trash
+    if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+        var exists bool
+        err := database.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM transactions WHERE id = $1 AND deleted_at IS NULL)`, transactionID).Scan(&exists)
+        if err != nil {
+            return err
+        }
+        if !exists {
+            return ErrNotFound
+        }
+    }
+    return nil
+}
+
+// UnlinkContribution stops counting a hand-linked transaction toward a goal
+func UnlinkContribution(goalID, transactionID int) error {
+    result, err := database.DB.Exec(`DELETE FROM goal_contributions WHERE goal_id = $1 AND transaction_id = $2`, goalID, transactionID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// ValidateGoal validates goal data
+func ValidateGoal(v *validator.Validator, goal *Goal) {
+    v.Check(validator.NotBlank(goal.Name), "name", "Goal name is required")
+    v.Check(validator.MaxLength(goal.Name, 100), "name", "Goal name cannot exceed 100 characters")
+    v.Check(goal.TargetAmount > 0, "target_amount", "Target amount must be greater than zero")
+    v.Check(goal.TargetAmount < 1e10, "target_amount", "Target amount is too large")
+    v.Check(!goal.StartDate.IsZero(), "start_date", "Start date is required")
+
+    if goal.TargetDate != nil {
+        v.Check(goal.TargetDate.After(goal.StartDate), "target_date", "Target date must be after the start date")
+    }
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// MaxNetWorthDays limits how many days one net worth query covers
+const MaxNetWorthDays = 3660
+
+// BalanceSnapshot records an account's balance at the end of a day. Later
+// transactions are counted from it. For liability accounts the balance is
+// the amount owed.
+type BalanceSnapshot struct {
+    ID          int       `json:"id"`
+    AccountID   int       `json:"account_id"`
+    AccountName string    `json:"account_name,omitempty"` // Used in joins
+    Currency    string    `json:"currency,omitempty"`     // The account's currency, used in joins
+    Date        time.Time `json:"date"`
+    Balance     float64   `json:"balance"`
+    Note        string    `json:"note"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NetWorthPoint is net worth at the end of one day
+type NetWorthPoint struct {
+    Date        time.Time `json:"date"`
+    Assets      float64   `json:"assets"`
+    Liabilities float64   `json:"liabilities"`
+    NetWorth    float64   `json:"net_worth"`
+}
+
+// AccountBalance is an account with its balance on a given day, in the
+// account's currency and converted into the base currency
+type AccountBalance struct {
+    Account
+    Balance   float64  `json:"balance"`
+    Converted *float64 `json:"converted"` // Nil when there is no exchange rate for the day
+}
+
+// Save records the snapshot, replacing any earlier one for the same
+// account and day
+func (s *BalanceSnapshot) Save() error {
+    stmt := `
+        INSERT INTO balance_snapshots (account_id, as_of, balance, note)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (account_id, as_of)
+        DO UPDATE SET balance = EXCLUDED.balance, note = EXCLUDED.note, updated_at = CURRENT_TIMESTAMP
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, s.AccountID, s.Date, s.Balance, s.Note).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+    return dbError(err)
+}
+
+// Delete removes a snapshot
+func (s *BalanceSnapshot) Delete() error {
+    result, err := database.DB.Exec(`DELETE FROM balance_snapshots WHERE id = $1`, s.ID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// GetBalanceSnapshots retrieves every snapshot, most recent first
+func GetBalanceSnapshots() ([]BalanceSnapshot, error) {
+    stmt := `
+        SELECT s.id, s.account_id, a.name, a.currency, s.as_of, s.balance, s.note, s.created_at, s.updated_at
+        FROM balance_snapshots s
+        JOIN accounts a ON s.account_id = a.id
+        ORDER BY s.as_of DESC, a.name`
+
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var snapshots []BalanceSnapshot
+
+    for rows.Next() {
+        var s BalanceSnapshot
+        if err := rows.Scan(&s.ID, &s.AccountID, &s.AccountName, &s.Currency, &s.Date, &s.Balance, &s.Note, &s.CreatedAt, &s.UpdatedAt); err != nil {
+            return nil, err
+        }
+        snapshots = append(snapshots, s)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return snapshots, nil
+}
+
+// balanceEvent changes an account balance at the end of a day: either a net
+// transaction amount or a snapshot that sets the balance
+type balanceEvent struct {
+    date     time.Time
+    amount   float64
+    snapshot bool
+}
+
+// GetNetWorth computes assets, liabilities and net worth for every day from
+// start to end, and each account's balance on end. An account's balance on a
+// day is its latest snapshot on or before that day plus the transactions
+// since; without a snapshot it is the sum of all its transactions.
+// Transactions that are not tied to an account are not counted. Totals are
+// in the base currency at each day's rate; balances in a currency without a
+// rate for the day are left out.
+func GetNetWorth(start, end time.Time) ([]NetWorthPoint, []AccountBalance, error) {
+    start = dateOnly(start)
+    end = dateOnly(end)
+
+    accounts, err := GetAllAccounts()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // Look up each foreign currency's daily rates once
+    base := GetPreferences().Currency
+    rates := make(map[string]map[time.Time]float64)
+    for _, account := range accounts {
+        if _, ok := rates[account.Currency]; ok || account.Currency == base {
+            continue
+        }
+        if rates[account.Currency], err = dailyRates(account.Currency, start, end); err != nil {
+            return nil, nil, err
+        }
+    }
+
+    // convert returns an account's balance in the base currency on day
+    convert := func(account Account, balance float64, day time.Time) (float64, bool) {
+        if account.Currency == base {
+            return balance, true
+        }
+        rate, ok := rates[account.Currency][day]
+        return balance * rate, ok
+    }
+
+    events, err := balanceEvents(end)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    balances := make(map[int]float64, len(accounts))
+    next := make(map[int]int, len(accounts)) // index of each account's next event
+
+    // advance applies every event up to and including day
+    advance := func(account Account, day time.Time) {
+        list := events[account.ID]
+        i := next[account.ID]
+        for ; i < len(list) && !list[i].date.After(day); i++ {
+            e := list[i]
+            switch {
+            case e.snapshot:
+                balances[account.ID] = e.amount
+            case account.IsLiability():
+                balances[account.ID] -= e.amount
+            default:
+                balances[account.ID] += e.amount
+            }
+        }
+        next[account.ID] = i
+    }
+
+    var points []NetWorthPoint
+    for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+        point := NetWorthPoint{Date: day}
+        for _, account := range accounts {
+            advance(account, day)
+            balance, ok := convert(account, balances[account.ID], day)
+            switch {
+            case !ok:
+            case account.IsLiability():
+                point.Liabilities += balance
+            default:
+                point.Assets += balance
+            }
+        }
+        point.NetWorth = point.Assets - point.Liabilities
+        points = append(points, point)
+    }
+
+    current := make([]AccountBalance, len(accounts))
+    for i, account := range accounts {
+        advance(account, end)
+        current[i] = AccountBalance{Account: account, Balance: balances[account.ID]}
+        if converted, ok := convert(account, balances[account.ID], end); ok {
+            current[i].Converted = &converted
+        }
+    }
+
+    return points, current, nil
+}
+
+// balanceEvents loads each account's daily transaction totals (income
+// positive, expenses negative) and snapshots up to end, in date order. On a
+// day with both, the transactions come first because the snapshot is the
+// end-of-day balance.
+func balanceEvents(end time.Time) (map[int][]balanceEvent, error) {
+    stmt := `
+        SELECT account_id, day, amount, snapshot FROM (
+            SELECT t.account_id, t.transaction_date AS day,
+                SUM(CASE WHEN c.type = 'income' THEN t.amount ELSE -t.amount END) AS amount,
+                FALSE AS snapshot
+            FROM transactions t
+            JOIN categories c ON t.category_id = c.id
+            WHERE t.deleted_at IS NULL AND t.account_id IS NOT NULL AND t.transaction_date <= $1
+            GROUP BY t.account_id, t.transaction_date
+            UNION ALL
+            SELECT account_id, as_of, balance, TRUE
+            FROM balance_snapshots
+            WHERE as_of <= $1
+        ) events
+        ORDER BY day, snapshot`
+
+    rows, err := database.DB.Query(stmt, end)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    events := make(map[int][]balanceEvent)
+    for rows.Next() {
+        var accountID int
+        var e balanceEvent
+        if err := rows.Scan(&accountID, &e.date, &e.amount, &e.snapshot); err != nil {
+            return nil, err
+        }
+        e.date = dateOnly(e.date)
+        events[accountID] = append(events[accountID], e)
+    }
+
+    return events, rows.Err()
+}
+
+// dateOnly drops the time of day, keeping the calendar date in UTC so it
+// compares equal to DATE columns read from the database
+func dateOnly(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ValidateBalanceSnapshot validates snapshot data
+func ValidateBalanceSnapshot(v *validator.Validator, s *BalanceSnapshot) {
+    v.Check(s.AccountID > 0, "account_id", "Please select an account")
+    v.Check(!s.Date.IsZero(), "date", "Date is required")
+    v.Check(!dateOnly(s.Date).After(Today()), "date", "Snapshots cannot be in the future")
+    v.Check(s.Balance > -1e12 && s.Balance < 1e12, "balance", "Balance is too large")
+    v.Check(validator.MaxLength(s.Note, 200), "note", "Note cannot exceed 200 characters")
+}
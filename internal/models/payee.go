@@ -0,0 +1,524 @@
+package models
+
+import (
+    "database/sql"
+    "strings"
+    "time"
+    "unicode"
+
+    "github.com/lib/pq"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// Payee is a merchant or person money is paid to or received from.
+// Transactions are linked to a payee when their normalized description
+// starts with one of its aliases.
+type Payee struct {
+    ID        int       `json:"id"`
+    Name      string    `json:"name"`
+    Aliases   []string  `json:"aliases"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PayeeSummary is a payee with totals over its live transactions
+type PayeeSummary struct {
+    Payee
+    Transactions int
+    Expense      float64
+    Income       float64
+    LastDate     *time.Time
+}
+
+// PayeeMonth totals one month of a payee's transactions
+type PayeeMonth struct {
+    Month        time.Time
+    Transactions int
+    Expense      float64
+    Income       float64
+}
+
+// NormalizePayee reduces a transaction description to the form aliases are
+// matched against: lower case words with punctuation, store numbers and
+// reference codes removed, so "AMZN Mktp US*2K4" becomes "amzn mktp us"
+func NormalizePayee(description string) string {
+    words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+
+    kept := words[:0]
+    for _, word := range words {
+        if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+            continue
+        }
+        kept = append(kept, word)
+    }
+    return strings.Join(kept, " ")
+}
+
+// auditSnapshot returns the fields of p recorded in the audit log
+func (p Payee) auditSnapshot() map[string]interface{} {
+    return map[string]interface{}{
+        "name": p.Name,
+    }
+}
+
+// Create adds a new payee with its aliases and links any unassigned
+// transactions that match them. The normalized name is always an alias.
+// It returns how many transactions were linked.
+func (p *Payee) Create(actor string) (int, error) {
+    stmt := `
+        INSERT INTO payees (name)
+        VALUES ($1)
+        RETURNING id, created_at, updated_at`
+
+    linked := 0
+    err := withTx(func(tx *sql.Tx) error {
+        if err := tx.QueryRow(stmt, p.Name).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+            return err
+        }
+
+        aliases := append([]string{NormalizePayee(p.Name)}, p.Aliases...)
+        p.Aliases = nil
+        for _, alias := range aliases {
+            added, err := addPayeeAlias(tx, p.ID, alias)
+            if err != nil {
+                return err
+            }
+            if added {
+                p.Aliases = append(p.Aliases, alias)
+            }
+        }
+
+        if err := recordAudit(tx, EntityPayee, p.ID, ActionCreate, nil, p.auditSnapshot(), actor); err != nil {
+            return err
+        }
+
+        var err error
+        linked, err = linkUnassignedTransactions(tx, p.ID, actor)
+        return err
+    })
+    return linked, dbError(err)
+}
+
+// AddAlias adds a normalized alias to the payee and links any unassigned
+// transactions it matches. It returns ErrConflict if another payee already
+// has the alias, and how many transactions were linked.
+func (p *Payee) AddAlias(alias string, actor string) (int, error) {
+    linked := 0
+    err := withTx(func(tx *sql.Tx) error {
+        added, err := addPayeeAlias(tx, p.ID, alias)
+        if err != nil {
+            return err
+        }
+        if !added {
+            return ErrConflict
+        }
+
+        linked, err = linkUnassignedTransactions(tx, p.ID, actor)
+        return err
+    })
+    return linked, dbError(err)
+}
+
+// RemoveAlias removes an alias from the payee. Transactions already linked
+// keep their payee.
+func (p *Payee) RemoveAlias(alias string) error {
+    result, err := database.DB.Exec(`DELETE FROM payee_aliases WHERE payee_id = $1 AND alias = $2`, p.ID, alias)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// addPayeeAlias inserts an alias unless it is empty or already exists. It
+// reports whether the alias now belongs to this payee because of the call.
+func addPayeeAlias(q queryer, payeeID int, alias string) (bool, error) {
+    if alias == "" {
+        return false, nil
+    }
+
+    var owner int
+    err := q.QueryRow(`SELECT payee_id FROM payee_aliases WHERE alias = $1`, alias).Scan(&owner)
+    if err == nil {
+        return false, nil
+    }
+    if err != sql.ErrNoRows {
+        return false, err
+    }
+
+    _, err = q.Exec(`INSERT INTO payee_aliases (payee_id, alias) VALUES ($1, $2)`, payeeID, alias)
+    return err == nil, err
+}
+
+// matchPayee finds the payee whose longest alias is a whole-word prefix of
+// the normalized description. It returns 0 if none matches.
+func matchPayee(q queryer, description string) (int, error) {
+    normalized := NormalizePayee(description)
+    if normalized == "" {
+        return 0, nil
+    }
+
+    // Normalized text holds only letters and spaces, so it needs no LIKE escaping
+    stmt := `
+        SELECT payee_id
+        FROM payee_aliases
+        WHERE $1 = alias OR $1 LIKE alias || ' %'
+        ORDER BY LENGTH(alias) DESC, id
+        LIMIT 1`
+
+    var payeeID int
+    err := q.QueryRow(stmt, normalized).Scan(&payeeID)
+    if err == sql.ErrNoRows {
+        return 0, nil
+    }
+    return payeeID, err
+}
+
+// linkUnassignedTransactions links live transactions without a payee to
+// payeeID where it is their best match, recording each change. Matching runs
+// in one statement using normalize_payee, the SQL twin of NormalizePayee.
+func linkUnassignedTransactions(tx *sql.Tx, payeeID int, actor string) (int, error) {
+    stmt := `
+        UPDATE transactions t
+        SET payee_id = $1, updated_at = CURRENT_TIMESTAMP
+        FROM (SELECT id, normalize_payee(description) AS normalized
+              FROM transactions
+              WHERE payee_id IS NULL AND deleted_at IS NULL) AS d
+        WHERE t.id = d.id AND t.payee_id IS NULL AND d.normalized <> ''
+          AND (SELECT a.payee_id
+               FROM payee_aliases a
+               WHERE d.normalized = a.alias OR d.normalized LIKE a.alias || ' %'
+               ORDER BY LENGTH(a.alias) DESC, a.id
+               LIMIT 1) = $1
+        RETURNING t.id`
+
+    rows, err := tx.Query(stmt, payeeID)
+    if err != nil {
+        return 0, err
+    }
+
+    var ids []int64
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, err
+    }
+    if len(ids) == 0 {
+        return 0, nil
+    }
+
+    rows, err = tx.Query(`SELECT`+transactionColumns+transactionJoins+` WHERE t.id = ANY($1)`, pq.Array(ids))
+    if err != nil {
+        return 0, err
+    }
+    var linked []Transaction
+    for rows.Next() {
+        var transaction Transaction
+        if err := scanTransaction(rows, &transaction); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        linked = append(linked, transaction)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, err
+    }
+
+    // Only the payee changed, so the before snapshot is the row without it
+    for _, after := range linked {
+        before := after
+        before.PayeeID, before.PayeeName = 0, ""
+        if err := recordAudit(tx, EntityTransaction, after.ID, ActionUpdate, before.auditSnapshot(), after.auditSnapshot(), actor); err != nil {
+            return 0, err
+        }
+    }
+    return len(linked), nil
+}
+
+// setTransactionPayee links a live transaction to a payee and records the change
+func setTransactionPayee(tx *sql.Tx, before Transaction, payeeID int, actor string) error {
+    stmt := `UPDATE transactions SET payee_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+    if _, err := tx.Exec(stmt, nullID(payeeID), before.ID); err != nil {
+        return err
+    }
+
+    after, err := getTransactionByID(tx, before.ID, false)
+    if err != nil {
+        return err
+    }
+    return recordAudit(tx, EntityTransaction, before.ID, ActionUpdate, before.auditSnapshot(), after.auditSnapshot(), actor)
+}
+
+// MergePayees folds source into target: its transactions, including those
+// in the trash, and its aliases move to target, its name becomes an alias of
+// target and source is removed. It returns how many live transactions moved.
+func MergePayees(sourceID, targetID int, actor string) (int, error) {
+    if sourceID == targetID {
+        return 0, &ValidationError{Field: "target_id", Message: "Choose a different payee to merge into"}
+    }
+
+    moved := 0
+    err := withTx(func(tx *sql.Tx) error {
+        source, err := getPayeeByID(tx, sourceID, true)
+        if err != nil {
+            return err
+        }
+        if _, err := getPayeeByID(tx, targetID, true); err != nil {
+            return err
+        }
+
+        stmt := `
+            SELECT` + transactionColumns + transactionJoins + `
+            WHERE t.payee_id = $1 AND t.deleted_at IS NULL
+            FOR UPDATE OF t`
+
+        rows, err := tx.Query(stmt, sourceID)
+        if err != nil {
+            return err
+        }
+
+        var transactions []Transaction
+        for rows.Next() {
+            var transaction Transaction
+            if err := scanTransaction(rows, &transaction); err != nil {
+                rows.Close()
+                return err
+            }
+            transactions = append(transactions, transaction)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return err
+        }
+
+        for _, transaction := range transactions {
+            if err := setTransactionPayee(tx, transaction, targetID, actor); err != nil {
+                return err
+            }
+        }
+        moved = len(transactions)
+
+        // Transactions in the trash follow without an audit entry each
+        if _, err := tx.Exec(`UPDATE transactions SET payee_id = $1 WHERE payee_id = $2`, targetID, sourceID); err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(`UPDATE payee_aliases SET payee_id = $1 WHERE payee_id = $2`, targetID, sourceID); err != nil {
+            return err
+        }
+        if _, err := addPayeeAlias(tx, targetID, NormalizePayee(source.Name)); err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(`DELETE FROM payees WHERE id = $1`, sourceID); err != nil {
+            return err
+        }
+        if _, err := tx.Exec(`UPDATE payees SET updated_at = CURRENT_TIMESTAMP WHERE id = $1`, targetID); err != nil {
+            return err
+        }
+
+        return recordAudit(tx, EntityPayee, sourceID, ActionDelete, source.auditSnapshot(), nil, actor)
+    })
+    return moved, dbError(err)
+}
+
+// GetAllPayees retrieves all payees ordered by name, without aliases
+func GetAllPayees() ([]Payee, error) {
+    stmt := `
+        SELECT id, name, created_at, updated_at
+        FROM payees
+        ORDER BY LOWER(name)`
+
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var payees []Payee
+
+    for rows.Next() {
+        var payee Payee
+        if err := rows.Scan(&payee.ID, &payee.Name, &payee.CreatedAt, &payee.UpdatedAt); err != nil {
+            return nil, err
+        }
+        payees = append(payees, payee)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return payees, nil
+}
+
+// GetPayeeSummaries retrieves every payee with its totals, biggest
+// spending first
+func GetPayeeSummaries() ([]PayeeSummary, error) {
+    stmt := `
+        SELECT p.id, p.name, p.created_at, p.updated_at,
+            COUNT(t.id),
+            COALESCE(SUM(CASE WHEN c.type = 'expense' THEN ` + baseAmount + ` ELSE 0 END), 0),
+            COALESCE(SUM(CASE WHEN c.type = 'income' THEN ` + baseAmount + ` ELSE 0 END), 0),
+            MAX(t.transaction_date)
+        FROM payees p
+        LEFT JOIN transactions t ON t.payee_id = p.id AND t.deleted_at IS NULL
+        LEFT JOIN categories c ON t.category_id = c.id
+        GROUP BY p.id
+        ORDER BY 6 DESC, LOWER(p.name)`
+
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var summaries []PayeeSummary
+
+    for rows.Next() {
+        var summary PayeeSummary
+        err := rows.Scan(
+            &summary.ID,
+            &summary.Name,
+            &summary.CreatedAt,
+            &summary.UpdatedAt,
+            &summary.Transactions,
+            &summary.Expense,
+            &summary.Income,
+            &summary.LastDate,
+        )
+        if err != nil {
+            return nil, err
+        }
+        summaries = append(summaries, summary)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return summaries, nil
+}
+
+// GetPayeeByID retrieves a payee with its aliases
+func GetPayeeByID(id int) (Payee, error) {
+    payee, err := getPayeeByID(database.DB, id, false)
+    return payee, dbError(err)
+}
+
+func getPayeeByID(q queryer, id int, forUpdate bool) (Payee, error) {
+    var payee Payee
+
+    stmt := `
+        SELECT id, name, created_at, updated_at
+        FROM payees
+        WHERE id = $1`
+    if forUpdate {
+        stmt += " FOR UPDATE"
+    }
+
+    err := q.QueryRow(stmt, id).Scan(&payee.ID, &payee.Name, &payee.CreatedAt, &payee.UpdatedAt)
+    if err != nil {
+        return payee, err
+    }
+
+    rows, err := q.Query(`SELECT alias FROM payee_aliases WHERE payee_id = $1 ORDER BY alias`, id)
+    if err != nil {
+        return payee, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var alias string
+        if err := rows.Scan(&alias); err != nil {
+            return payee, err
+        }
+        payee.Aliases = append(payee.Aliases, alias)
+    }
+
+    return payee, rows.Err()
+}
+
+// GetPayeeMonthlyTotals totals a payee's live transactions by month, most
+// recent first
+func GetPayeeMonthlyTotals(id int) ([]PayeeMonth, error) {
+    stmt := `
+        SELECT date_trunc('month', t.transaction_date) AS month,
+            COUNT(*),
+            COALESCE(SUM(CASE WHEN c.type = 'expense' THEN ` + baseAmount + ` ELSE 0 END), 0),
+            COALESCE(SUM(CASE WHEN c.type = 'income' THEN ` + baseAmount + ` ELSE 0 END), 0)
+        FROM transactions t
+        JOIN categories c ON t.category_id = c.id
+        WHERE t.payee_id = $1 AND t.deleted_at IS NULL
+        GROUP BY month
+        ORDER BY month DESC`
+
+    rows, err := database.DB.Query(stmt, id)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var months []PayeeMonth
+
+    for rows.Next() {
+        var month PayeeMonth
+        if err := rows.Scan(&month.Month, &month.Transactions, &month.Expense, &month.Income); err != nil {
+            return nil, err
+        }
+        months = append(months, month)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return months, nil
+}
+
+// ValidatePayee validates payee data
+func ValidatePayee(v *validator.Validator, payee *Payee) {
+    v.Check(validator.NotBlank(payee.Name), "name", "Payee name is required")
+    v.Check(validator.MaxLength(payee.Name, 100), "name", "Payee name cannot exceed 100 characters")
+    v.Check(NormalizePayee(payee.Name) != "", "name", "Payee name must contain letters")
+
+    for _, alias := range payee.Aliases {
+        v.Check(validator.MaxLength(alias, 200), "aliases", "Aliases cannot exceed 200 characters")
+    }
+}
+
+// ParseAliases splits comma-separated aliases and normalizes each,
+// dropping empty and duplicate ones
+func ParseAliases(value string) []string {
+    aliases := []string{}
+    seen := map[string]bool{}
+
+    for _, alias := range strings.Split(value, ",") {
+        alias = NormalizePayee(alias)
+        if alias == "" || seen[alias] {
+            continue
+        }
+        seen[alias] = true
+        aliases = append(aliases, alias)
+    }
+    return aliases
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestNormalizePayee(t *testing.T) {
+    tests := []struct {
+        description string
+        want        string
+    }{
+        {"AMZN Mktp US*2K4", "amzn mktp us"},
+        {"Tesco Stores 3297", "tesco stores"},
+        {"  STARBUCKS   #1234  London ", "starbucks london"},
+        {"SQ *Joe's Coffee", "sq joe s coffee"},
+        {"Café Zürich", "café zürich"},
+        {"PAYPAL*ACME-LTD REF:AB12CD", "paypal acme ltd ref"},
+        {"123 456", ""},
+        {"", ""},
+    }
+
+    for _, tt := range tests {
+        if got := NormalizePayee(tt.description); got != tt.want {
+            t.Errorf("NormalizePayee(%q) = %q, want %q", tt.description, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+    "fmt"
+    "time"
+)
+
+// Period is a range of whole days used to total transactions. Month,
+// quarter and year periods follow the calendar; budget periods follow the
+// pay cycle in the preferences; 30d and 90d roll back from their end date;
+// custom periods are chosen freely.
+type Period struct {
+    Kind  string
+    Start time.Time
+    End   time.Time // Inclusive
+}
+
+// NewPeriod returns the period of the given kind containing date, or for a
+// rolling period the one ending on date. Custom periods cannot be built this
+// way; use NewCustomPeriod.
+func NewPeriod(kind string, date time.Time) (Period, error) {
+    date = dateOnly(date)
+    p := Period{Kind: kind}
+
+    switch kind {
+    case "month":
+        p.Start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+        p.End = p.Start.AddDate(0, 1, -1)
+    case "quarter":
+        month := date.Month() - (date.Month()-1)%3
+        p.Start = time.Date(date.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+        p.End = p.Start.AddDate(0, 3, -1)
+    case "year":
+        p.Start = time.Date(date.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+        p.End = p.Start.AddDate(1, 0, -1)
+    case "budget":
+        p.Start, p.End = GetPreferences().BudgetPeriod(date)
+    case "30d":
+        p.Start, p.End = date.AddDate(0, 0, -29), date
+    case "90d":
+        p.Start, p.End = date.AddDate(0, 0, -89), date
+    default:
+        return p, &ValidationError{Field: "period", Message: "Please choose a valid period"}
+    }
+    return p, nil
+}
+
+// NewCustomPeriod returns the period from start to end, both included
+func NewCustomPeriod(start, end time.Time) (Period, error) {
+    p := Period{Kind: "custom", Start: dateOnly(start), End: dateOnly(end)}
+    if p.Start.After(p.End) {
+        return p, &ValidationError{Field: "start", Message: "Start date cannot be after the end date"}
+    }
+    return p, nil
+}
+
+// Previous returns the period of the same kind just before p
+func (p Period) Previous() Period {
+    switch p.Kind {
+    case "month", "quarter", "year", "budget", "30d", "90d":
+        previous, _ := NewPeriod(p.Kind, p.Start.AddDate(0, 0, -1))
+        return previous
+    }
+    start, end := PreviousPeriod(p.Start, p.End)
+    return Period{Kind: p.Kind, Start: start, End: end}
+}
+
+// Next returns the period of the same kind just after p
+func (p Period) Next() Period {
+    switch p.Kind {
+    case "month", "quarter", "year", "budget":
+        next, _ := NewPeriod(p.Kind, p.End.AddDate(0, 0, 1))
+        return next
+    }
+    days := p.Days()
+    return Period{Kind: p.Kind, Start: p.Start.AddDate(0, 0, days), End: p.End.AddDate(0, 0, days)}
+}
+
+// YearAgo returns the same period one year earlier. A period ending on
+// 29 February ends on the 28th the year before; a budget period is the one
+// that was running a year before this one started.
+func (p Period) YearAgo() Period {
+    switch p.Kind {
+    case "month", "quarter", "year", "budget":
+        previous, _ := NewPeriod(p.Kind, yearBefore(p.Start))
+        return previous
+    }
+    return Period{Kind: p.Kind, Start: yearBefore(p.Start), End: yearBefore(p.End)}
+}
+
+// Days is the number of days in the period
+func (p Period) Days() int {
+    return int(p.End.Sub(p.Start).Hours()/24) + 1
+}
+
+// Label describes the period, such as "March 2024" or "Q1 2024"
+func (p Period) Label() string {
+    switch p.Kind {
+    case "month":
+        return p.Start.Format("January 2006")
+    case "quarter":
+        return fmt.Sprintf("Q%d %d", (p.Start.Month()-1)/3+1, p.Start.Year())
+    case "year":
+        return p.Start.Format("2006")
+    case "budget":
+        // Budget periods that match a calendar month are named like one
+        if p.Start.Day() == 1 && p.End.Equal(p.Start.AddDate(0, 1, -1)) {
+            return p.Start.Format("January 2006")
+        }
+    }
+    if p.Start.Year() == p.End.Year() {
+        return p.Start.Format("Jan 2") + " – " + p.End.Format("Jan 2, 2006")
+    }
+    return p.Start.Format("Jan 2, 2006") + " – " + p.End.Format("Jan 2, 2006")
+}
+
+// yearBefore moves a date back one year, keeping it in the same month
+func yearBefore(t time.Time) time.Time {
+    before := t.AddDate(-1, 0, 0)
+    if before.Month() != t.Month() {
+        // 29 February has no match, so use the last day of February
+        before = before.AddDate(0, 0, -before.Day())
+    }
+    return before
+}
+
+// BudgetPeriod returns the first and last day of the budget period
+// containing date. Monthly periods start on PeriodStartDay, or on the last
+// day of months too short for it; weekly and biweekly periods start on a
+// payday counted from PayDate.
+func (p Preferences) BudgetPeriod(date time.Time) (time.Time, time.Time) {
+    date = dateOnly(date)
+
+    if days := payCycleDays[p.PayCycle]; days > 0 && p.PayDate != nil {
+        offset := int(date.Sub(dateOnly(*p.PayDate)).Hours()/24) % days
+        if offset < 0 {
+            offset += days
+        }
+        start := date.AddDate(0, 0, -offset)
+        return start, start.AddDate(0, 0, days-1)
+    }
+
+    day := p.PeriodStartDay
+    if day < 1 {
+        day = 1
+    }
+    start := dayOfMonth(date.Year(), date.Month(), day)
+    if date.Before(start) {
+        start = dayOfMonth(date.Year(), date.Month()-1, day)
+    }
+    next := dayOfMonth(start.Year(), start.Month()+1, day)
+    return start, next.AddDate(0, 0, -1)
+}
+
+// payCycleDays is the length of the pay cycles that repeat every few weeks
+var payCycleDays = map[string]int{"weekly": 7, "biweekly": 14}
+
+// dayOfMonth returns the given day of a month, or the month's last day if
+// it is shorter
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+    first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+    if last := first.AddDate(0, 1, -1).Day(); day > last {
+        day = last
+    }
+    return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// MaxPivotDimensions limits how many dimensions one pivot groups by
+const MaxPivotDimensions = 3
+
+// pivotDimensions maps each dimension name to the SQL expression it groups
+// by. Only these expressions are ever placed in a pivot query.
+var pivotDimensions = map[string]string{
+    "month":    "to_char(t.transaction_date, 'YYYY-MM')",
+    "week":     "to_char(date_trunc('week', t.transaction_date), 'YYYY-MM-DD')", // The Monday starting the week
+    "category": "c.name",
+    "type":     "c.type",
+    "account":  "COALESCE(a.name, '')",
+    "tag":      "COALESCE(tg.tag, '')",
+    "payee":    "COALESCE(p.name, '')",
+    "currency": "t.currency",
+}
+
+// pivotMeasures maps each measure name to its aggregate over the amounts
+// converted into the base currency
+var pivotMeasures = map[string]string{
+    "sum":   "SUM(" + baseAmount + ")",
+    "count": "COUNT(*)",
+    "avg":   "ROUND(AVG(" + baseAmount + "), 2)",
+    "min":   "MIN(" + baseAmount + ")",
+    "max":   "MAX(" + baseAmount + ")",
+}
+
+// PivotDimensionNames and PivotMeasureNames list what a pivot accepts, in
+// the order they are documented
+var (
+    PivotDimensionNames = []string{"month", "week", "category", "type", "account", "tag", "payee", "currency"}
+    PivotMeasureNames   = []string{"sum", "count", "avg", "min", "max"}
+)
+
+// PivotQuery describes a pivot: the transactions matching Filter are grouped
+// by Dimensions and each group gets every one of Measures
+type PivotQuery struct {
+    Dimensions []string
+    Measures   []string
+    Filter     TransactionFilter
+}
+
+// PivotRow is one group of a pivot. Keys line up with the query's
+// dimensions and Values with its measures; a value is nil when the group
+// has nothing to aggregate.
+type PivotRow struct {
+    Keys   []string   `json:"keys"`
+    Values []*float64 `json:"values"`
+}
+
+// Pivot is the result of a PivotQuery
+type Pivot struct {
+    Dimensions []string   `json:"dimensions"`
+    Measures   []string   `json:"measures"`
+    Rows       []PivotRow `json:"rows"`
+    Total      PivotRow   `json:"total"` // Every matching transaction as one group
+}
+
+// Validate checks the query only names known dimensions and measures
+func (q PivotQuery) Validate() error {
+    if len(q.Dimensions) > MaxPivotDimensions {
+        return &ValidationError{Field: "dimensions", Message: fmt.Sprintf("A pivot can group by at most %d dimensions", MaxPivotDimensions)}
+    }
+    seen := make(map[string]bool)
+    for _, name := range q.Dimensions {
+        if _, ok := pivotDimensions[name]; !ok {
+            return &ValidationError{Field: "dimensions", Message: fmt.Sprintf("Unknown dimension %q; use %s", name, strings.Join(PivotDimensionNames, ", "))}
+        }
+        if seen[name] {
+            return &ValidationError{Field: "dimensions", Message: fmt.Sprintf("Dimension %q is listed twice", name)}
+        }
+        seen[name] = true
+    }
+
+    if len(q.Measures) == 0 {
+        return &ValidationError{Field: "measures", Message: "Please choose at least one measure"}
+    }
+    for _, name := range q.Measures {
+        if _, ok := pivotMeasures[name]; !ok {
+            return &ValidationError{Field: "measures", Message: fmt.Sprintf("Unknown measure %q; use %s", name, strings.Join(PivotMeasureNames, ", "))}
+        }
+    }
+    return nil
+}
+
+// sql compiles the query. Dimension and measure names are looked up rather
+// than copied into the statement, and filter values are passed as
+// parameters. Without dimensions the single row is the grand total.
+func (q PivotQuery) sql() (string, []interface{}) {
+    var columns, groups []string
+    for _, name := range q.Dimensions {
+        columns = append(columns, pivotDimensions[name])
+        groups = append(groups, pivotDimensions[name])
+    }
+    for _, name := range q.Measures {
+        columns = append(columns, pivotMeasures[name])
+    }
+
+    joins := transactionJoins
+    for _, name := range q.Dimensions {
+        // A transaction counts once under each of its tags, and once under
+        // "" when it has none
+        if name == "tag" {
+            joins += `
+        LEFT JOIN LATERAL unnest(t.tags) AS tg(tag) ON true`
+        }
+    }
+
+    grouping := ""
+    if len(groups) > 0 {
+        grouping = `
+        GROUP BY ` + strings.Join(groups, ", ") + `
+        ORDER BY ` + strings.Join(groups, ", ")
+    }
+
+    conditions, args := q.Filter.where(nil)
+    stmt := `
+        SELECT ` + strings.Join(columns, ", ") + joins + `
+        WHERE t.deleted_at IS NULL` + conditions + grouping
+    return stmt, args
+}
+
+// GetPivot runs a pivot query. The total is queried on its own, without
+// the dimensions, so a transaction with several tags is only counted once.
+func GetPivot(q PivotQuery) (*Pivot, error) {
+    if err := q.Validate(); err != nil {
+        return nil, err
+    }
+
+    pivot := &Pivot{Dimensions: q.Dimensions, Measures: q.Measures, Rows: []PivotRow{}}
+
+    totals, err := queryPivotRows(PivotQuery{Measures: q.Measures, Filter: q.Filter})
+    if err != nil {
+        return nil, err
+    }
+    pivot.Total = totals[0]
+    if len(q.Dimensions) == 0 {
+        return pivot, nil
+    }
+
+    if pivot.Rows, err = queryPivotRows(q); err != nil {
+        return nil, err
+    }
+    return pivot, nil
+}
+
+// queryPivotRows runs the query and reads one PivotRow per group
+func queryPivotRows(q PivotQuery) ([]PivotRow, error) {
+    stmt, args := q.sql()
+    rows, err := database.DB.Query(stmt, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    result := []PivotRow{}
+
+    for rows.Next() {
+        row := PivotRow{
+            Keys:   make([]string, len(q.Dimensions)),
+            Values: make([]*float64, len(q.Measures)),
+        }
+        keys := make([]*string, len(q.Dimensions))
+        dest := make([]interface{}, 0, len(keys)+len(row.Values))
+        for i := range keys {
+            dest = append(dest, &keys[i])
+        }
+        for i := range row.Values {
+            dest = append(dest, &row.Values[i])
+        }
+
+        if err := rows.Scan(dest...); err != nil {
+            return nil, err
+        }
+
+        for i, key := range keys {
+            if key != nil {
+                row.Keys[i] = *key
+            }
+        }
+        result = append(result, row)
+    }
+
+    return result, rows.Err()
+}
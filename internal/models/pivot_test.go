@@ -0,0 +1,95 @@
+package models
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestPivotQueryValidate(t *testing.T) {
+    tests := []struct {
+        name      string
+        query     PivotQuery
+        wantField string // Empty when the query is valid
+    }{
+        {"valid", PivotQuery{Dimensions: []string{"month", "tag"}, Measures: []string{"sum", "count"}}, ""},
+        {"no dimensions", PivotQuery{Measures: []string{"avg"}}, ""},
+        {"unknown dimension", PivotQuery{Dimensions: []string{"t.amount; DROP TABLE transactions"}, Measures: []string{"sum"}}, "dimensions"},
+        {"repeated dimension", PivotQuery{Dimensions: []string{"month", "month"}, Measures: []string{"sum"}}, "dimensions"},
+        {"too many dimensions", PivotQuery{Dimensions: []string{"month", "category", "account", "payee"}, Measures: []string{"sum"}}, "dimensions"},
+        {"unknown measure", PivotQuery{Dimensions: []string{"month"}, Measures: []string{"SUM(t.amount)"}}, "measures"},
+        {"no measures", PivotQuery{Dimensions: []string{"month"}}, "measures"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := tt.query.Validate()
+            if tt.wantField == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error: %v", err)
+                }
+                return
+            }
+            var verr *ValidationError
+            if !errors.As(err, &verr) || verr.Field != tt.wantField {
+                t.Errorf("error = %v, want a ValidationError on %s", err, tt.wantField)
+            }
+        })
+    }
+}
+
+func TestPivotQuerySQL(t *testing.T) {
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+    q := PivotQuery{
+        Dimensions: []string{"category", "tag"},
+        Measures:   []string{"sum", "count"},
+        Filter:     TransactionFilter{CategoryType: "expense", Tag: "o'brien", StartDate: start, EndDate: end},
+    }
+    stmt, args := q.sql()
+
+    wantArgs := []interface{}{"expense", "o'brien", start, end}
+    if !reflect.DeepEqual(args, wantArgs) {
+        t.Errorf("args = %v, want %v", args, wantArgs)
+    }
+
+    // Only the whitelisted expressions and placeholders make it into the
+    // statement, never the filter values themselves
+    for _, want := range []string{
+        pivotDimensions["category"],
+        pivotDimensions["tag"],
+        pivotMeasures["sum"],
+        pivotMeasures["count"],
+        "unnest(t.tags)",
+        "c.type = $1",
+        "$2 = ANY(t.tags)",
+        "t.transaction_date >= $3",
+        "t.transaction_date <= $4",
+        "GROUP BY " + pivotDimensions["category"] + ", " + pivotDimensions["tag"],
+    } {
+        if !strings.Contains(stmt, want) {
+            t.Errorf("statement is missing %q:\n%s", want, stmt)
+        }
+    }
+    for _, unwanted := range []string{"expense", "o'brien", "2024", pivotDimensions["month"], pivotMeasures["avg"]} {
+        if strings.Contains(stmt, unwanted) {
+            t.Errorf("statement contains %q:\n%s", unwanted, stmt)
+        }
+    }
+}
+
+func TestPivotQuerySQLTotal(t *testing.T) {
+    // Without dimensions the query is the grand total: no grouping and no
+    // tag join to count a transaction more than once
+    stmt, args := PivotQuery{Measures: []string{"sum"}, Filter: TransactionFilter{Tag: "travel"}}.sql()
+
+    if strings.Contains(stmt, "GROUP BY") || strings.Contains(stmt, "unnest") {
+        t.Errorf("total statement groups or joins tags:\n%s", stmt)
+    }
+    if !reflect.DeepEqual(args, []interface{}{"travel"}) {
+        t.Errorf("args = %v, want [travel]", args)
+    }
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+    "sync"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/locale"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// Preferences are the time zone dates are computed in, the locale and
+// currency amounts are shown with, and the pay cycle budget periods follow.
+// There are no user accounts, so one set applies to everyone.
+type Preferences struct {
+    TimeZone       string     `json:"time_zone"` // IANA name, such as Europe/Berlin
+    Locale         string     `json:"locale"`
+    Currency       string     `json:"currency"`
+    PayCycle       string     `json:"pay_cycle"`        // One of PayCycles
+    PeriodStartDay int        `json:"period_start_day"` // Day monthly periods start on; later than the month's last day means the last day
+    PayDate        *time.Time `json:"pay_date"`         // Any payday of a weekly or biweekly cycle
+    UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PayCycles lists how budget periods can repeat
+var PayCycles = []string{"monthly", "weekly", "biweekly"}
+
+// DefaultPreferences apply until preferences are loaded from the database
+var DefaultPreferences = Preferences{TimeZone: "UTC", Locale: "en-US", Currency: "USD", PayCycle: "monthly", PeriodStartDay: 1}
+
+// currentPreferences caches the saved preferences; every page and date
+// calculation reads them
+var currentPreferences struct {
+    sync.RWMutex
+    prefs    Preferences
+    location *time.Location
+}
+
+// LoadPreferences reads the saved preferences into the cache. It is called
+// once at startup; Save keeps the cache up to date after that.
+func LoadPreferences() error {
+    var p Preferences
+    stmt := `
+        SELECT time_zone, locale, currency, pay_cycle, period_start_day, pay_date, updated_at
+        FROM preferences
+        WHERE id = 1`
+    err := database.DB.QueryRow(stmt).Scan(&p.TimeZone, &p.Locale, &p.Currency, &p.PayCycle, &p.PeriodStartDay, &p.PayDate, &p.UpdatedAt)
+    if err != nil {
+        return dbError(err)
+    }
+    return setPreferences(p)
+}
+
+// GetPreferences returns the current preferences
+func GetPreferences() Preferences {
+    currentPreferences.RLock()
+    defer currentPreferences.RUnlock()
+    if currentPreferences.location == nil {
+        return DefaultPreferences
+    }
+    return currentPreferences.prefs
+}
+
+// Save stores the preferences and makes them current
+func (p *Preferences) Save() error {
+    stmt := `
+        INSERT INTO preferences (id, time_zone, locale, currency, pay_cycle, period_start_day, pay_date)
+        VALUES (1, $1, $2, $3, $4, $5, $6)
+        ON CONFLICT (id)
+        DO UPDATE SET time_zone = EXCLUDED.time_zone, locale = EXCLUDED.locale,
+            currency = EXCLUDED.currency, pay_cycle = EXCLUDED.pay_cycle,
+            period_start_day = EXCLUDED.period_start_day, pay_date = EXCLUDED.pay_date,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING updated_at`
+
+    args := []interface{}{p.TimeZone, p.Locale, p.Currency, p.PayCycle, p.PeriodStartDay, p.PayDate}
+    if err := database.DB.QueryRow(stmt, args...).Scan(&p.UpdatedAt); err != nil {
+        return dbError(err)
+    }
+    return setPreferences(*p)
+}
+
+func setPreferences(p Preferences) error {
+    location, err := time.LoadLocation(p.TimeZone)
+    if err != nil {
+        return err
+    }
+
+    currentPreferences.Lock()
+    currentPreferences.prefs = p
+    currentPreferences.location = location
+    currentPreferences.Unlock()
+    return nil
+}
+
+// Location returns the preferred time zone, or UTC before preferences are
+// loaded
+func Location() *time.Location {
+    currentPreferences.RLock()
+    defer currentPreferences.RUnlock()
+    if currentPreferences.location == nil {
+        return time.UTC
+    }
+    return currentPreferences.location
+}
+
+// Now returns the current time in the preferred time zone
+func Now() time.Time {
+    return time.Now().In(Location())
+}
+
+// Today returns the current date in the preferred time zone, as a UTC
+// midnight so it compares equal to dates read from the database and forms
+func Today() time.Time {
+    return dateOnly(Now())
+}
+
+// Formatter writes numbers, amounts and dates with the preferred locale
+// and currency
+func (p Preferences) Formatter() locale.Formatter {
+    return locale.NewFormatter(p.Locale, p.Currency)
+}
+
+// ValidatePreferences validates preference data
+func ValidatePreferences(v *validator.Validator, p *Preferences) {
+    _, err := time.LoadLocation(p.TimeZone)
+    v.Check(p.TimeZone != "" && err == nil, "time_zone", "Please enter a time zone such as Europe/London")
+    _, ok := locale.Lookup(p.Locale)
+    v.Check(ok, "locale", "Please select a language and region")
+    _, ok = locale.LookupCurrency(p.Currency)
+    v.Check(ok, "currency", "Please select a currency")
+
+    switch p.PayCycle {
+    case "monthly":
+        v.Check(p.PeriodStartDay >= 1 && p.PeriodStartDay <= 31, "period_start_day", "Please enter a day between 1 and 31")
+    case "weekly", "biweekly":
+        v.Check(p.PayDate != nil, "pay_date", "Please enter the date of a recent payday")
+    default:
+        v.AddError("pay_cycle", "Please select a pay cycle")
+    }
+}
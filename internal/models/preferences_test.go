@@ -0,0 +1,45 @@
+package models
+
+import (
+    "testing"
+    "time"
+)
+
+func TestBudgetPeriod(t *testing.T) {
+    payday := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        name  string
+        prefs Preferences
+        date  string
+        want  string
+    }{
+        {"calendar month", Preferences{PayCycle: "monthly", PeriodStartDay: 1}, "2024-02-15", "2024-02-01 to 2024-02-29"},
+        {"unset start day is the 1st", Preferences{PayCycle: "monthly"}, "2024-02-15", "2024-02-01 to 2024-02-29"},
+        {"25th, before the start day", Preferences{PayCycle: "monthly", PeriodStartDay: 25}, "2024-01-04", "2023-12-25 to 2024-01-24"},
+        {"25th, on the start day", Preferences{PayCycle: "monthly", PeriodStartDay: 25}, "2024-02-25", "2024-02-25 to 2024-03-24"},
+        {"25th, after the start day", Preferences{PayCycle: "monthly", PeriodStartDay: 25}, "2024-03-30", "2024-03-25 to 2024-04-24"},
+        {"31st across the new year", Preferences{PayCycle: "monthly", PeriodStartDay: 31}, "2024-01-04", "2023-12-31 to 2024-01-30"},
+        {"31st before a short February", Preferences{PayCycle: "monthly", PeriodStartDay: 31}, "2024-02-25", "2024-01-31 to 2024-02-28"},
+        {"31st clamped to 29 February", Preferences{PayCycle: "monthly", PeriodStartDay: 31}, "2024-02-29", "2024-02-29 to 2024-03-30"},
+        {"weekly before the payday", Preferences{PayCycle: "weekly", PayDate: &payday}, "2024-01-04", "2023-12-29 to 2024-01-04"},
+        {"weekly on the payday", Preferences{PayCycle: "weekly", PayDate: &payday}, "2024-01-05", "2024-01-05 to 2024-01-11"},
+        {"weekly weeks later", Preferences{PayCycle: "weekly", PayDate: &payday}, "2024-02-25", "2024-02-23 to 2024-02-29"},
+        {"biweekly before the payday", Preferences{PayCycle: "biweekly", PayDate: &payday}, "2024-01-04", "2023-12-22 to 2024-01-04"},
+        {"biweekly months later", Preferences{PayCycle: "biweekly", PayDate: &payday}, "2024-03-30", "2024-03-29 to 2024-04-11"},
+        {"weekly without a payday is monthly", Preferences{PayCycle: "weekly", PeriodStartDay: 1}, "2024-02-15", "2024-02-01 to 2024-02-29"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            date, err := time.Parse("2006-01-02", tt.date)
+            if err != nil {
+                t.Fatalf("bad test date %q: %v", tt.date, err)
+            }
+            start, end := tt.prefs.BudgetPeriod(date)
+            if got := start.Format("2006-01-02") + " to " + end.Format("2006-01-02"); got != tt.want {
+                t.Errorf("BudgetPeriod(%s) = %s, want %s", tt.date, got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// RecurringFrequencies lists how often a recurring item can repeat
+var RecurringFrequencies = []string{"weekly", "biweekly", "monthly", "quarterly", "yearly"}
+
+// RecurringItem is scheduled income or a bill, such as a salary or rent. Its
+// category decides whether it adds to or takes from the account.
+type RecurringItem struct {
+    ID           int        `json:"id"`
+    Name         string     `json:"name"`
+    Amount       float64    `json:"amount"`
+    CategoryID   int        `json:"category_id"`
+    CategoryName string     `json:"category_name,omitempty"` // Used in joins
+    CategoryType string     `json:"category_type,omitempty"` // Used in joins
+    AccountID    int        `json:"account_id"`
+    AccountName  string     `json:"account_name,omitempty"` // Used in joins
+    Frequency    string     `json:"frequency"`
+    StartDate    time.Time  `json:"start_date"`
+    EndDate      *time.Time `json:"end_date,omitempty"`
+    Enabled      bool       `json:"enabled"`
+    CreatedAt    time.Time  `json:"created_at"`
+    UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// SignedAmount is the item's effect on the account balance: positive for
+// income, negative for expenses
+func (item RecurringItem) SignedAmount() float64 {
+    if item.CategoryType == "income" {
+        return item.Amount
+    }
+    return -item.Amount
+}
+
+// occurrence returns the nth date the item falls on, counting the start
+// date as 0. Monthly dates past the end of a shorter month move to its last
+// day rather than into the next month.
+func (item RecurringItem) occurrence(n int) time.Time {
+    start := item.StartDate
+    switch item.Frequency {
+    case "weekly":
+        return start.AddDate(0, 0, 7*n)
+    case "biweekly":
+        return start.AddDate(0, 0, 14*n)
+    }
+
+    months := n
+    switch item.Frequency {
+    case "quarterly":
+        months = 3 * n
+    case "yearly":
+        months = 12 * n
+    }
+
+    return addMonths(start, months)
+}
+
+// Occurrences returns the dates the item falls on from from to to inclusive
+func (item RecurringItem) Occurrences(from, to time.Time) []time.Time {
+    if item.EndDate != nil && item.EndDate.Before(to) {
+        to = *item.EndDate
+    }
+
+    var dates []time.Time
+    for n := 0; ; n++ {
+        date := item.occurrence(n)
+        if date.After(to) {
+            return dates
+        }
+        if !date.Before(from) {
+            dates = append(dates, date)
+        }
+    }
+}
+
+// NextOccurrence returns the first date on or after from the item falls on,
+// or nil when it has ended
+func (item RecurringItem) NextOccurrence(from time.Time) *time.Time {
+    dates := item.Occurrences(from, from.AddDate(1, 0, 1))
+    if len(dates) == 0 {
+        return nil
+    }
+    return &dates[0]
+}
+
+const recurringItemColumns = `
+        ri.id, ri.name, ri.amount, ri.category_id, c.name, c.type, ri.account_id, a.name,
+        ri.frequency, ri.start_date, ri.end_date, ri.enabled, ri.created_at, ri.updated_at
+        FROM recurring_items ri
+        JOIN categories c ON ri.category_id = c.id
+        JOIN accounts a ON ri.account_id = a.id`
+
+func scanRecurringItem(row rowScanner, item *RecurringItem) error {
+    return row.Scan(
+        &item.ID,
+        &item.Name,
+        &item.Amount,
+        &item.CategoryID,
+        &item.CategoryName,
+        &item.CategoryType,
+        &item.AccountID,
+        &item.AccountName,
+        &item.Frequency,
+        &item.StartDate,
+        &item.EndDate,
+        &item.Enabled,
+        &item.CreatedAt,
+        &item.UpdatedAt,
+    )
+}
+
+// Create adds a new recurring item to the database
+func (item *RecurringItem) Create() error {
+    stmt := `
+        INSERT INTO recurring_items (name, amount, category_id, account_id, frequency, start_date, end_date, enabled)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, item.Name, item.Amount, item.CategoryID, item.AccountID, item.Frequency,
+        item.StartDate, item.EndDate, item.Enabled).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+    return dbError(err)
+}
+
+// Update saves changes to an existing recurring item
+func (item *RecurringItem) Update() error {
+    stmt := `
+        UPDATE recurring_items
+        SET name = $1, amount = $2, category_id = $3, account_id = $4, frequency = $5,
+            start_date = $6, end_date = $7, enabled = $8, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $9
+        RETURNING updated_at`
+
+    err := database.DB.QueryRow(stmt, item.Name, item.Amount, item.CategoryID, item.AccountID, item.Frequency,
+        item.StartDate, item.EndDate, item.Enabled, item.ID).Scan(&item.UpdatedAt)
+    return dbError(err)
+}
+
+// Delete removes a recurring item
+func (item *RecurringItem) Delete() error {
+    result, err := database.DB.Exec(`DELETE FROM recurring_items WHERE id = $1`, item.ID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// GetAllRecurringItems retrieves every recurring item grouped by account
+func GetAllRecurringItems() ([]RecurringItem, error) {
+    return queryRecurringItems(`
+        SELECT` + recurringItemColumns + `
+        ORDER BY a.name, ri.name`)
+}
+
+// getAccountRecurringItems retrieves the enabled items of one account
+func getAccountRecurringItems(accountID int) ([]RecurringItem, error) {
+    return queryRecurringItems(`
+        SELECT` + recurringItemColumns + `
+        WHERE ri.account_id = $1 AND ri.enabled
+        ORDER BY ri.name`, accountID)
+}
+
+func queryRecurringItems(stmt string, args ...interface{}) ([]RecurringItem, error) {
+    rows, err := database.DB.Query(stmt, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var items []RecurringItem
+
+    for rows.Next() {
+        var item RecurringItem
+        if err := scanRecurringItem(rows, &item); err != nil {
+            return nil, err
+        }
+        items = append(items, item)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return items, nil
+}
+
+// GetRecurringItemByID retrieves a recurring item by its ID
+func GetRecurringItemByID(id int) (RecurringItem, error) {
+    var item RecurringItem
+
+    stmt := `
+        SELECT` + recurringItemColumns + `
+        WHERE ri.id = $1`
+
+    err := scanRecurringItem(database.DB.QueryRow(stmt, id), &item)
+    return item, dbError(err)
+}
+
+// ValidateRecurringItem validates recurring item data
+func ValidateRecurringItem(v *validator.Validator, item *RecurringItem) {
+    v.Check(validator.NotBlank(item.Name), "name", "Name is required")
+    v.Check(validator.MaxLength(item.Name, 100), "name", "Name cannot exceed 100 characters")
+    v.Check(item.Amount > 0, "amount", "Amount must be greater than zero")
+    v.Check(item.Amount < 1e10, "amount", "Amount is too large")
+    v.Check(item.CategoryID > 0, "category_id", "Please select a category")
+    v.Check(item.AccountID > 0, "account_id", "Please select an account")
+    v.Check(!item.StartDate.IsZero(), "start_date", "First date is required")
+
+    valid := false
+    for _, f := range RecurringFrequencies {
+        if item.Frequency == f {
+            valid = true
+        }
+    }
+    v.Check(valid, "frequency", "Please select how often it repeats")
+
+    if item.EndDate != nil {
+        v.Check(!item.EndDate.Before(item.StartDate), "end_date", "End date cannot be before the first date")
+    }
+}
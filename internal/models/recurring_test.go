@@ -0,0 +1,60 @@
+package models
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// recurringDate builds a UTC test date
+func recurringDate(year int, month time.Month, day int) time.Time {
+    return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestOccurrences(t *testing.T) {
+    end := recurringDate(2024, 3, 15)
+
+    tests := []struct {
+        name      string
+        frequency string
+        start     time.Time
+        end       *time.Time
+        from, to  time.Time
+        want      string
+    }{
+        {"weekly from the start", "weekly", recurringDate(2024, 1, 1), nil, recurringDate(2024, 1, 1), recurringDate(2024, 1, 29), "2024-01-01 2024-01-08 2024-01-15 2024-01-22 2024-01-29"},
+        {"weekly skips dates before from", "weekly", recurringDate(2024, 1, 1), nil, recurringDate(2024, 1, 10), recurringDate(2024, 1, 31), "2024-01-15 2024-01-22 2024-01-29"},
+        {"biweekly", "biweekly", recurringDate(2024, 1, 5), nil, recurringDate(2024, 1, 1), recurringDate(2024, 2, 29), "2024-01-05 2024-01-19 2024-02-02 2024-02-16"},
+        {"monthly on the 31st keeps its day", "monthly", recurringDate(2024, 1, 31), nil, recurringDate(2024, 1, 1), recurringDate(2024, 5, 31), "2024-01-31 2024-02-29 2024-03-31 2024-04-30 2024-05-31"},
+        {"quarterly", "quarterly", recurringDate(2023, 11, 30), nil, recurringDate(2024, 1, 1), recurringDate(2024, 12, 31), "2024-02-29 2024-05-30 2024-08-30 2024-11-30"},
+        {"yearly from 29 February", "yearly", recurringDate(2024, 2, 29), nil, recurringDate(2024, 1, 1), recurringDate(2028, 12, 31), "2024-02-29 2025-02-28 2026-02-28 2027-02-28 2028-02-29"},
+        {"end date stops the item", "weekly", recurringDate(2024, 3, 1), &end, recurringDate(2024, 3, 1), recurringDate(2024, 3, 31), "2024-03-01 2024-03-08 2024-03-15"},
+        {"ended before the range", "monthly", recurringDate(2024, 1, 1), &end, recurringDate(2024, 4, 1), recurringDate(2024, 6, 30), ""},
+        {"starts after the range", "monthly", recurringDate(2024, 7, 1), nil, recurringDate(2024, 1, 1), recurringDate(2024, 6, 30), ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            item := RecurringItem{Frequency: tt.frequency, StartDate: tt.start, EndDate: tt.end}
+            var got []string
+            for _, date := range item.Occurrences(tt.from, tt.to) {
+                got = append(got, date.Format("2006-01-02"))
+            }
+            if strings.Join(got, " ") != tt.want {
+                t.Errorf("Occurrences = %q, want %q", strings.Join(got, " "), tt.want)
+            }
+        })
+    }
+}
+
+func TestNextOccurrence(t *testing.T) {
+    end := recurringDate(2024, 3, 31)
+    item := RecurringItem{Frequency: "monthly", StartDate: recurringDate(2024, 1, 31), EndDate: &end}
+
+    if next := item.NextOccurrence(recurringDate(2024, 2, 1)); next == nil || !next.Equal(recurringDate(2024, 2, 29)) {
+        t.Errorf("NextOccurrence(2024-02-01) = %v, want 2024-02-29", next)
+    }
+    if next := item.NextOccurrence(recurringDate(2024, 4, 1)); next != nil {
+        t.Errorf("NextOccurrence after the end date = %v, want nil", next)
+    }
+}
@@ -0,0 +1,256 @@
+package models
+
+import (
+    "strings"
+    "time"
+
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// MaxTrendMonths limits how many months one trend report covers
+const MaxTrendMonths = 120
+
+// MonthlyTrend is the income and expense of one calendar month
+type MonthlyTrend struct {
+    Month       time.Time `json:"-"`
+    Label       string    `json:"month"` // YYYY-MM
+    Income      float64   `json:"income"`
+    Expense     float64   `json:"expense"`
+    Net         float64   `json:"net"`
+    SavingsRate *float64  `json:"savings_rate"` // Percent of income kept; nil without income
+}
+
+// TrendReport is a run of months with their totals
+type TrendReport struct {
+    Start       time.Time      `json:"start"`
+    End         time.Time      `json:"end"`
+    Months      []MonthlyTrend `json:"months"`
+    Income      float64        `json:"income"`
+    Expense     float64        `json:"expense"`
+    Net         float64        `json:"net"`
+    SavingsRate *float64       `json:"savings_rate"`
+}
+
+// SavingsRateString formats the savings rate for display, or "" without
+// income
+func (m MonthlyTrend) SavingsRateString() string {
+    return formatOptionalPercent(m.SavingsRate, false)
+}
+
+// SavingsRateString formats the overall savings rate for display
+func (r TrendReport) SavingsRateString() string {
+    return formatOptionalPercent(r.SavingsRate, false)
+}
+
+// formatOptionalPercent formats a percentage with an optional sign, or ""
+// when it is nil
+func formatOptionalPercent(p *float64, signed bool) string {
+    if p == nil {
+        return ""
+    }
+    f := GetPreferences().Formatter()
+    if signed {
+        s := f.Percent(*p, 0)
+        if !strings.HasPrefix(s, "-") {
+            s = "+" + s
+        }
+        return s
+    }
+    return f.Percent(*p, 1)
+}
+
+// savingsRate returns the share of income not spent, or nil without income
+func savingsRate(income, expense float64) *float64 {
+    if income <= 0 {
+        return nil
+    }
+    rate := (income - expense) / income * 100
+    return &rate
+}
+
+// GetMonthlyTrends totals income and expense for every month from the month
+// of start to the month of end, including months without transactions
+func GetMonthlyTrends(start, end time.Time) (*TrendReport, error) {
+    start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+    end = time.Date(end.Year(), end.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+
+    stmt := `
+        SELECT m.month::date,
+            COALESCE(SUM(` + baseAmount + `) FILTER (WHERE c.type = 'income'), 0),
+            COALESCE(SUM(` + baseAmount + `) FILTER (WHERE c.type = 'expense'), 0)
+        FROM generate_series($1::timestamp, $2::timestamp, interval '1 month') AS m(month)
+        LEFT JOIN transactions t ON date_trunc('month', t.transaction_date::timestamp) = m.month
+            AND t.transaction_date BETWEEN $1 AND $2 AND t.deleted_at IS NULL
+        LEFT JOIN categories c ON t.category_id = c.id
+        GROUP BY m.month
+        ORDER BY m.month`
+
+    rows, err := database.DB.Query(stmt, start, end)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    report := &TrendReport{Start: start, End: end}
+
+    for rows.Next() {
+        var m MonthlyTrend
+        if err := rows.Scan(&m.Month, &m.Income, &m.Expense); err != nil {
+            return nil, err
+        }
+        m.Label = m.Month.Format("2006-01")
+        m.Net = m.Income - m.Expense
+        m.SavingsRate = savingsRate(m.Income, m.Expense)
+
+        report.Income += m.Income
+        report.Expense += m.Expense
+        report.Months = append(report.Months, m)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    report.Net = report.Income - report.Expense
+    report.SavingsRate = savingsRate(report.Income, report.Expense)
+
+    return report, nil
+}
+
+// CategoryShare is one category's part of a breakdown, with the same
+// period before for comparison
+type CategoryShare struct {
+    CategoryID    int      `json:"category_id"`
+    CategoryName  string   `json:"category_name"`
+    Amount        float64  `json:"amount"`
+    Count         int      `json:"count"`
+    Share         float64  `json:"share"` // Percent of the total
+    Previous      float64  `json:"previous"`
+    Change        float64  `json:"change"`
+    ChangePercent *float64 `json:"change_percent"` // Nil when there was nothing before
+}
+
+// ChangePercentString formats the change from the previous period, such as
+// "+12%", or "" when there was nothing before
+func (c CategoryShare) ChangePercentString() string {
+    return formatOptionalPercent(c.ChangePercent, true)
+}
+
+// CategoryBreakdown splits the income or expense of a period by category
+type CategoryBreakdown struct {
+    Type          string          `json:"type"`
+    Start         time.Time       `json:"start"`
+    End           time.Time       `json:"end"`
+    PreviousStart time.Time       `json:"previous_start"`
+    PreviousEnd   time.Time       `json:"previous_end"`
+    Total         float64         `json:"total"`
+    PreviousTotal float64         `json:"previous_total"`
+    Change        float64         `json:"change"`
+    Categories    []CategoryShare `json:"categories"` // Largest first
+}
+
+// PreviousPeriod returns the period of the same length just before start to
+// end. A run of whole calendar months maps to the same number of months
+// before it, so March compares with February rather than the last 31 days.
+func PreviousPeriod(start, end time.Time) (time.Time, time.Time) {
+    start, end = dateOnly(start), dateOnly(end)
+    if start.Day() == 1 && end.AddDate(0, 0, 1).Day() == 1 {
+        months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+        return start.AddDate(0, -months, 0), start.AddDate(0, 0, -1)
+    }
+
+    days := int(end.Sub(start).Hours()/24) + 1
+    return start.AddDate(0, 0, -days), start.AddDate(0, 0, -1)
+}
+
+// GetCategoryBreakdown totals the transactions matching filter by category
+// and compares each with the previous period. The filter needs a start and
+// end date; its category type defaults to expense and its category is
+// ignored.
+func GetCategoryBreakdown(filter TransactionFilter) (*CategoryBreakdown, error) {
+    filter.CategoryID = 0
+    if filter.CategoryType == "" {
+        filter.CategoryType = "expense"
+    }
+
+    b := &CategoryBreakdown{
+        Type:  filter.CategoryType,
+        Start: dateOnly(filter.StartDate),
+        End:   dateOnly(filter.EndDate),
+    }
+    b.PreviousStart, b.PreviousEnd = PreviousPeriod(b.Start, b.End)
+
+    current, err := categoryTotals(filter)
+    if err != nil {
+        return nil, err
+    }
+
+    previousFilter := filter
+    previousFilter.StartDate, previousFilter.EndDate = b.PreviousStart, b.PreviousEnd
+    previous, err := categoryTotals(previousFilter)
+    if err != nil {
+        return nil, err
+    }
+
+    // Categories only seen in the previous period are listed with zero
+    index := make(map[int]int, len(current))
+    for _, share := range current {
+        index[share.CategoryID] = len(b.Categories)
+        b.Total += share.Amount
+        b.Categories = append(b.Categories, share)
+    }
+    for _, share := range previous {
+        b.PreviousTotal += share.Amount
+        i, ok := index[share.CategoryID]
+        if !ok {
+            i = len(b.Categories)
+            b.Categories = append(b.Categories, CategoryShare{CategoryID: share.CategoryID, CategoryName: share.CategoryName})
+        }
+        b.Categories[i].Previous = share.Amount
+    }
+
+    b.Change = b.Total - b.PreviousTotal
+    for i := range b.Categories {
+        share := &b.Categories[i]
+        if b.Total > 0 {
+            share.Share = share.Amount / b.Total * 100
+        }
+        share.Change = share.Amount - share.Previous
+        if share.Previous > 0 {
+            percent := share.Change / share.Previous * 100
+            share.ChangePercent = &percent
+        }
+    }
+
+    return b, nil
+}
+
+// categoryTotals sums the transactions matching filter per category, largest
+// first
+func categoryTotals(filter TransactionFilter) ([]CategoryShare, error) {
+    conditions, args := filter.where(nil)
+    stmt := `
+        SELECT c.id, c.name, COUNT(*), COALESCE(SUM(` + baseAmount + `), 0)
+        FROM transactions t
+        JOIN categories c ON t.category_id = c.id
+        WHERE t.deleted_at IS NULL` + conditions + `
+        GROUP BY c.id, c.name
+        ORDER BY 4 DESC, c.name`
+
+    rows, err := database.DB.Query(stmt, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var shares []CategoryShare
+    for rows.Next() {
+        var share CategoryShare
+        if err := rows.Scan(&share.CategoryID, &share.CategoryName, &share.Count, &share.Amount); err != nil {
+            return nil, err
+        }
+        shares = append(shares, share)
+    }
+
+    return shares, rows.Err()
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// parsePeriod splits a "start/end" test range into its dates
+func parsePeriod(t *testing.T, value string) (time.Time, time.Time) {
+    t.Helper()
+    start, end, _ := strings.Cut(value, "/")
+    s, err := time.Parse("2006-01-02", start)
+    if err != nil {
+        t.Fatalf("bad test period %q: %v", value, err)
+    }
+    e, err := time.Parse("2006-01-02", end)
+    if err != nil {
+        t.Fatalf("bad test period %q: %v", value, err)
+    }
+    return s, e
+}
+
+func TestPreviousPeriod(t *testing.T) {
+    tests := []struct {
+        name   string
+        period string
+        want   string
+    }{
+        {"calendar month", "2026-03-01/2026-03-31", "2026-02-01/2026-02-28"},
+        {"month into a leap February", "2024-03-01/2024-03-31", "2024-02-01/2024-02-29"},
+        {"quarter", "2026-01-01/2026-03-31", "2025-10-01/2025-12-31"},
+        {"year", "2025-01-01/2025-12-31", "2024-01-01/2024-12-31"},
+        {"week", "2026-03-05/2026-03-11", "2026-02-26/2026-03-04"},
+        {"partial month counts days", "2026-03-01/2026-03-15", "2026-02-14/2026-02-28"},
+        {"single day", "2026-03-01/2026-03-01", "2026-02-28/2026-02-28"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            start, end := PreviousPeriod(parsePeriod(t, tt.period))
+            if got := start.Format("2006-01-02") + "/" + end.Format("2006-01-02"); got != tt.want {
+                t.Errorf("PreviousPeriod(%s) = %s, want %s", tt.period, got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,481 @@
+package models
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/validator"
+)
+
+// Ways a rule can match a transaction description
+const (
+    MatchNone     = ""
+    MatchContains = "contains"
+    MatchRegex    = "regex"
+)
+
+// maxPreviewMatches caps how many matching transactions a preview lists
+const maxPreviewMatches = 100
+
+// Rule sets the category, tags or description of transactions that match its
+// conditions. Rules run in ascending priority order.
+type Rule struct {
+    ID                 int       `json:"id"`
+    Name               string    `json:"name"`
+    Priority           int       `json:"priority"`
+    Enabled            bool      `json:"enabled"`
+    DescriptionMatch   string    `json:"description_match"`
+    DescriptionPattern string    `json:"description_pattern"`
+    AmountMin          *float64  `json:"amount_min,omitempty"`
+    AmountMax          *float64  `json:"amount_max,omitempty"`
+    AccountID          int       `json:"account_id,omitempty"`
+    AccountName        string    `json:"account_name,omitempty"` // Used in joins
+    SetCategoryID      int       `json:"set_category_id,omitempty"`
+    CategoryName       string    `json:"category_name,omitempty"` // Used in joins
+    AddTags            []string  `json:"add_tags"`
+    SetDescription     string    `json:"set_description"`
+    CreatedAt          time.Time `json:"created_at"`
+    UpdatedAt          time.Time `json:"updated_at"`
+
+    pattern *regexp.Regexp
+}
+
+// RuleMatch is an existing transaction a rule would change
+type RuleMatch struct {
+    Transaction Transaction
+    Changes     []FieldChange
+}
+
+// RulePreview is the result of testing a rule against existing transactions
+type RulePreview struct {
+    Matches []RuleMatch // at most maxPreviewMatches
+    Total   int         // every matching transaction
+}
+
+const ruleColumns = `
+        r.id, r.name, r.priority, r.enabled, r.description_match, r.description_pattern,
+        r.amount_min, r.amount_max, COALESCE(r.account_id, 0), COALESCE(a.name, ''),
+        COALESCE(r.set_category_id, 0), COALESCE(c.name, ''), r.add_tags, r.set_description,
+        r.created_at, r.updated_at
+        FROM rules r
+        LEFT JOIN accounts a ON r.account_id = a.id
+        LEFT JOIN categories c ON r.set_category_id = c.id`
+
+func scanRule(row rowScanner, rule *Rule) error {
+    var amountMin, amountMax sql.NullFloat64
+    err := row.Scan(
+        &rule.ID,
+        &rule.Name,
+        &rule.Priority,
+        &rule.Enabled,
+        &rule.DescriptionMatch,
+        &rule.DescriptionPattern,
+        &amountMin,
+        &amountMax,
+        &rule.AccountID,
+        &rule.AccountName,
+        &rule.SetCategoryID,
+        &rule.CategoryName,
+        pq.Array(&rule.AddTags),
+        &rule.SetDescription,
+        &rule.CreatedAt,
+        &rule.UpdatedAt,
+    )
+    if err != nil {
+        return err
+    }
+
+    if amountMin.Valid {
+        rule.AmountMin = &amountMin.Float64
+    }
+    if amountMax.Valid {
+        rule.AmountMax = &amountMax.Float64
+    }
+    return rule.compile()
+}
+
+// Create adds a new rule to the database
+func (r *Rule) Create() error {
+    stmt := `
+        INSERT INTO rules (name, priority, enabled, description_match, description_pattern,
+            amount_min, amount_max, account_id, set_category_id, add_tags, set_description)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id, created_at, updated_at`
+
+    err := database.DB.QueryRow(stmt, r.writeArgs()...).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+    return dbError(err)
+}
+
+// Update saves changes to an existing rule
+func (r *Rule) Update() error {
+    stmt := `
+        UPDATE rules
+        SET name = $1, priority = $2, enabled = $3, description_match = $4, description_pattern = $5,
+            amount_min = $6, amount_max = $7, account_id = $8, set_category_id = $9, add_tags = $10,
+            set_description = $11, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $12
+        RETURNING updated_at`
+
+    args := append(r.writeArgs(), r.ID)
+    err := database.DB.QueryRow(stmt, args...).Scan(&r.UpdatedAt)
+    return dbError(err)
+}
+
+// Delete removes a rule. Transactions it already changed are left as they are.
+func (r *Rule) Delete() error {
+    result, err := database.DB.Exec(`DELETE FROM rules WHERE id = $1`, r.ID)
+    if err != nil {
+        return dbError(err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (r *Rule) writeArgs() []interface{} {
+    tags := r.AddTags
+    if tags == nil {
+        tags = []string{}
+    }
+    return []interface{}{
+        r.Name, r.Priority, r.Enabled, r.DescriptionMatch, r.DescriptionPattern,
+        r.AmountMin, r.AmountMax, nullID(r.AccountID), nullID(r.SetCategoryID),
+        pq.Array(tags), r.SetDescription,
+    }
+}
+
+// GetAllRules retrieves every rule in evaluation order
+func GetAllRules() ([]Rule, error) {
+    return queryRules(database.DB, `SELECT`+ruleColumns+` ORDER BY r.priority, r.id`)
+}
+
+// getEnabledRules retrieves the rules that run on new transactions
+func getEnabledRules() ([]Rule, error) {
+    return queryRules(database.DB, `SELECT`+ruleColumns+` WHERE r.enabled ORDER BY r.priority, r.id`)
+}
+
+// GetRuleByID retrieves a rule by its ID
+func GetRuleByID(id int) (Rule, error) {
+    var rule Rule
+    err := scanRule(database.DB.QueryRow(`SELECT`+ruleColumns+` WHERE r.id = $1`, id), &rule)
+    return rule, dbError(err)
+}
+
+func queryRules(q queryer, stmt string, args ...interface{}) ([]Rule, error) {
+    rows, err := q.Query(stmt, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var rules []Rule
+
+    for rows.Next() {
+        var rule Rule
+        if err := scanRule(rows, &rule); err != nil {
+            return nil, err
+        }
+        rules = append(rules, rule)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return rules, nil
+}
+
+// compile prepares the description pattern for matching
+func (r *Rule) compile() error {
+    r.pattern = nil
+    if r.DescriptionMatch != MatchRegex {
+        return nil
+    }
+
+    pattern, err := regexp.Compile("(?i)" + r.DescriptionPattern)
+    if err != nil {
+        return err
+    }
+    r.pattern = pattern
+    return nil
+}
+
+// Matches reports whether every condition of the rule holds for t
+func (r *Rule) Matches(t Transaction) bool {
+    switch r.DescriptionMatch {
+    case MatchContains:
+        if !strings.Contains(strings.ToLower(t.Description), strings.ToLower(r.DescriptionPattern)) {
+            return false
+        }
+    case MatchRegex:
+        if r.pattern == nil && r.compile() != nil {
+            return false
+        }
+        if !r.pattern.MatchString(t.Description) {
+            return false
+        }
+    }
+
+    if r.AmountMin != nil && t.Amount < *r.AmountMin {
+        return false
+    }
+    if r.AmountMax != nil && t.Amount > *r.AmountMax {
+        return false
+    }
+    if r.AccountID > 0 && t.AccountID != r.AccountID {
+        return false
+    }
+    return true
+}
+
+// applyTo makes the rule's changes to t, overriding its category and
+// description. CategoryName must already be filled in on the rule.
+func (r *Rule) applyTo(t *Transaction) {
+    if r.SetCategoryID > 0 {
+        t.CategoryID = r.SetCategoryID
+        t.CategoryName = r.CategoryName
+    }
+    if len(r.AddTags) > 0 {
+        t.Tags = ParseTags(t.TagString() + "," + strings.Join(r.AddTags, ","))
+    }
+    if r.SetDescription != "" {
+        t.Description = r.SetDescription
+    }
+}
+
+// ApplyRules runs the enabled rules against a new transaction. Conditions are
+// checked against the transaction as entered. Rules only fill in what was
+// left empty: the first matching rule that sets a category decides it when
+// none was chosen, and likewise for the description. Tags from every
+// matching rule are added.
+func ApplyRules(t *Transaction) error {
+    rules, err := getEnabledRules()
+    if err != nil {
+        return err
+    }
+
+    applyRules(t, rules)
+    return nil
+}
+
+// applyRules is ApplyRules with the rules already loaded, in priority order
+func applyRules(t *Transaction, rules []Rule) {
+    original := *t
+    categorySet := t.CategoryID > 0
+    descriptionSet := strings.TrimSpace(t.Description) != ""
+
+    for i := range rules {
+        if !rules[i].Matches(original) {
+            continue
+        }
+
+        // Later rules only add their tags once a change has been decided
+        rule := rules[i]
+        if categorySet {
+            rule.SetCategoryID = 0
+        }
+        if descriptionSet {
+            rule.SetDescription = ""
+        }
+        rule.applyTo(t)
+
+        categorySet = categorySet || rule.SetCategoryID > 0
+        descriptionSet = descriptionSet || rule.SetDescription != ""
+    }
+}
+
+// PreviewRule lists the existing transactions the rule would change if it
+// were applied retroactively. The rule does not need to be saved.
+func PreviewRule(rule Rule) (*RulePreview, error) {
+    if err := rule.fillCategoryName(); err != nil {
+        return nil, err
+    }
+    if err := rule.compile(); err != nil {
+        return nil, &ValidationError{Field: "description_pattern", Message: "Invalid regular expression"}
+    }
+
+    transactions, err := GetTransactions(TransactionFilter{})
+    if err != nil {
+        return nil, err
+    }
+
+    preview := &RulePreview{}
+    for _, transaction := range transactions {
+        if !rule.Matches(transaction) {
+            continue
+        }
+
+        after := transaction
+        rule.applyTo(&after)
+        preview.Total++
+        if len(preview.Matches) < maxPreviewMatches {
+            preview.Matches = append(preview.Matches, RuleMatch{
+                Transaction: transaction,
+                Changes:     snapshotChanges(transaction.auditSnapshot(), after.auditSnapshot()),
+            })
+        }
+    }
+
+    return preview, nil
+}
+
+// ApplyRuleRetroactively applies a saved rule to every existing transaction
+// it matches, in one database transaction. Rows that would become invalid
+// roll back the whole batch, as with bulk actions.
+func ApplyRuleRetroactively(id int, actor string) (*BulkResult, error) {
+    rule, err := GetRuleByID(id)
+    if err != nil {
+        return nil, err
+    }
+
+    stmt := `
+        SELECT` + transactionColumns + transactionJoins + `
+        WHERE t.deleted_at IS NULL
+        ORDER BY t.transaction_date, t.id
+        FOR UPDATE OF t`
+
+    result := &BulkResult{Operation: BulkOperation{Action: "rule"}}
+
+    err = withTx(func(tx *sql.Tx) error {
+        rows, err := tx.Query(stmt)
+        if err != nil {
+            return err
+        }
+
+        var matches []Transaction
+        for rows.Next() {
+            var transaction Transaction
+            if err := scanTransaction(rows, &transaction); err != nil {
+                rows.Close()
+                return err
+            }
+            if rule.Matches(transaction) {
+                matches = append(matches, transaction)
+            }
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return err
+        }
+
+        invalid := false
+        for _, before := range matches {
+            after := before
+            after.Tags = append([]string(nil), before.Tags...)
+            rule.applyTo(&after)
+
+            row, err := saveBulkRow(tx, before, after, actor)
+            if err != nil {
+                return err
+            }
+            if len(row.Errors) > 0 {
+                invalid = true
+            }
+            result.Rows = append(result.Rows, row)
+        }
+
+        if invalid {
+            return errBulkInvalid
+        }
+        return nil
+    })
+
+    if errors.Is(err, errBulkInvalid) {
+        return result, nil
+    }
+    if err != nil {
+        return nil, dbError(err)
+    }
+
+    result.Applied = true
+    return result, nil
+}
+
+func (r *Rule) fillCategoryName() error {
+    if r.SetCategoryID == 0 || r.CategoryName != "" {
+        return nil
+    }
+
+    category, err := GetCategoryByID(r.SetCategoryID)
+    if errors.Is(err, ErrNotFound) {
+        return &ValidationError{Field: "set_category_id", Message: "The selected category no longer exists"}
+    }
+    if err != nil {
+        return err
+    }
+    r.CategoryName = category.Name
+    return nil
+}
+
+// ValidateRule validates rule data
+func ValidateRule(v *validator.Validator, rule *Rule) {
+    v.Check(validator.NotBlank(rule.Name), "name", "Rule name is required")
+    v.Check(validator.MaxLength(rule.Name, 100), "name", "Rule name cannot exceed 100 characters")
+    v.Check(rule.Priority >= 0 && rule.Priority <= 10000, "priority", "Priority must be between 0 and 10000")
+
+    switch rule.DescriptionMatch {
+    case MatchNone:
+    case MatchContains, MatchRegex:
+        v.Check(validator.NotBlank(rule.DescriptionPattern), "description_pattern", "Enter the text to match")
+        v.Check(validator.MaxLength(rule.DescriptionPattern, 500), "description_pattern", "Pattern cannot exceed 500 characters")
+        if rule.DescriptionMatch == MatchRegex {
+            if _, err := regexp.Compile(rule.DescriptionPattern); err != nil {
+                v.AddError("description_pattern", "Invalid regular expression: "+strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+            }
+        }
+    default:
+        v.AddError("description_match", "Please select how to match the description")
+    }
+
+    if rule.AmountMin != nil {
+        v.Check(*rule.AmountMin >= 0, "amount_min", "Minimum amount cannot be negative")
+    }
+    if rule.AmountMin != nil && rule.AmountMax != nil {
+        v.Check(*rule.AmountMin <= *rule.AmountMax, "amount_max", "Maximum amount must not be less than the minimum")
+    }
+
+    hasCondition := rule.DescriptionMatch != MatchNone || rule.AmountMin != nil || rule.AmountMax != nil || rule.AccountID > 0
+    v.Check(hasCondition, "conditions", "Add at least one condition")
+
+    hasAction := rule.SetCategoryID > 0 || len(rule.AddTags) > 0 || validator.NotBlank(rule.SetDescription)
+    v.Check(hasAction, "actions", "Choose at least one thing for the rule to set")
+
+    v.Check(validator.MaxLength(rule.SetDescription, 500), "set_description", "Description cannot exceed 500 characters")
+    v.Check(len(rule.AddTags) <= maxTags, "add_tags", fmt.Sprintf("A rule can add at most %d tags", maxTags))
+}
+
+// AmountMinString formats the minimum amount for a form field
+func (r Rule) AmountMinString() string {
+    return formatOptionalAmount(r.AmountMin)
+}
+
+// AmountMaxString formats the maximum amount for a form field
+func (r Rule) AmountMaxString() string {
+    return formatOptionalAmount(r.AmountMax)
+}
+
+// TagString joins the tags the rule adds for display in a form field
+func (r Rule) TagString() string {
+    return strings.Join(r.AddTags, ", ")
+}
+
+func formatOptionalAmount(amount *float64) string {
+    if amount == nil {
+        return ""
+    }
+    return strconv.FormatFloat(*amount, 'f', 2, 64)
+}
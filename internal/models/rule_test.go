@@ -0,0 +1,84 @@
+package models
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestApplyRulesPrecedence(t *testing.T) {
+    rules := []Rule{
+        {Name: "Groceries", DescriptionMatch: MatchContains, DescriptionPattern: "tesco", SetCategoryID: 1, CategoryName: "Groceries", AddTags: []string{"food"}},
+        {Name: "Any Tesco", DescriptionMatch: MatchContains, DescriptionPattern: "tesco", SetCategoryID: 2, CategoryName: "Shopping", AddTags: []string{"shop"}},
+        {Name: "Refunds", DescriptionMatch: MatchRegex, DescriptionPattern: `^refund`, SetCategoryID: 3, CategoryName: "Income", SetDescription: "Refund"},
+        {Name: "Large", DescriptionMatch: MatchContains, AmountMin: floatPtr(1000), SetDescription: "Large payment"},
+    }
+
+    tests := []struct {
+        name            string
+        in              Transaction
+        wantCategoryID  int
+        wantDescription string
+        wantTags        []string
+    }{
+        {
+            name:            "first matching rule fills an empty category",
+            in:              Transaction{Description: "TESCO STORES", Amount: 20},
+            wantCategoryID:  1,
+            wantDescription: "TESCO STORES",
+            wantTags:        []string{"food", "shop"},
+        },
+        {
+            name:            "chosen category is kept",
+            in:              Transaction{Description: "TESCO STORES", Amount: 20, CategoryID: 7},
+            wantCategoryID:  7,
+            wantDescription: "TESCO STORES",
+            wantTags:        []string{"food", "shop"},
+        },
+        {
+            name:            "entered description is kept",
+            in:              Transaction{Description: "Refund from shop", Amount: 20},
+            wantCategoryID:  3,
+            wantDescription: "Refund from shop",
+            wantTags:        []string{},
+        },
+        {
+            name:            "empty description is filled in",
+            in:              Transaction{Amount: 2500},
+            wantCategoryID:  0,
+            wantDescription: "Large payment",
+            wantTags:        []string{},
+        },
+        {
+            name:            "no matching rule changes nothing",
+            in:              Transaction{Description: "Rent", Amount: 900, Tags: []string{"home"}},
+            wantCategoryID:  0,
+            wantDescription: "Rent",
+            wantTags:        []string{"home"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := tt.in
+            applyRules(&got, rules)
+
+            if got.CategoryID != tt.wantCategoryID {
+                t.Errorf("CategoryID = %d, want %d", got.CategoryID, tt.wantCategoryID)
+            }
+            if got.Description != tt.wantDescription {
+                t.Errorf("Description = %q, want %q", got.Description, tt.wantDescription)
+            }
+            tags := got.Tags
+            if tags == nil {
+                tags = []string{}
+            }
+            if !reflect.DeepEqual(tags, tt.wantTags) {
+                t.Errorf("Tags = %v, want %v", tags, tt.wantTags)
+            }
+        })
+    }
+}
+
+func floatPtr(v float64) *float64 {
+    return &v
+}
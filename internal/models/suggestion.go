@@ -0,0 +1,198 @@
+package models
+
+import (
+    "math"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+
+    "github.com/bryan/finance-tracker/internal/classifier"
+    "github.com/bryan/finance-tracker/internal/database"
+)
+
+// Sources of a category suggestion
+const (
+    SuggestionRule    = "rule"
+    SuggestionHistory = "history"
+)
+
+const (
+    // maxTrainingExamples limits training to the most recent transactions
+    maxTrainingExamples = 5000
+
+    // minTrainingExamples is how much history is needed before suggesting
+    minTrainingExamples = 5
+
+    // MinSuggestionConfidence is the probability a learned category needs
+    // before the form pre-selects it
+    MinSuggestionConfidence = 0.5
+)
+
+// CategorySuggestion is a likely category for a transaction
+type CategorySuggestion struct {
+    CategoryID   int     `json:"category_id"`
+    CategoryName string  `json:"category_name"`
+    CategoryType string  `json:"category_type"`
+    Confidence   float64 `json:"confidence"`
+    Source       string  `json:"source"`
+}
+
+// suggestionModel caches the classifier until the transactions change
+var suggestionModel struct {
+    sync.Mutex
+    classifier *classifier.NaiveBayes
+    version    string
+}
+
+// SuggestCategories ranks the likely categories for t, best first. A rule
+// that would set the category always comes first with full confidence;
+// otherwise the categories are predicted from how transactions with similar
+// descriptions, amounts and accounts were categorized before. Nothing is
+// returned when the description shares no words with past transactions.
+func SuggestCategories(t Transaction, limit int) ([]CategorySuggestion, error) {
+    categories, err := GetAllCategories()
+    if err != nil {
+        return nil, err
+    }
+    byID := make(map[int]Category, len(categories))
+    for _, category := range categories {
+        byID[category.ID] = category
+    }
+
+    var suggestions []CategorySuggestion
+
+    ruled := t
+    ruled.CategoryID = 0
+    if err := ApplyRules(&ruled); err != nil {
+        return nil, err
+    }
+    if category, ok := byID[ruled.CategoryID]; ok {
+        suggestions = append(suggestions, CategorySuggestion{
+            CategoryID:   category.ID,
+            CategoryName: category.Name,
+            CategoryType: category.Type,
+            Confidence:   1,
+            Source:       SuggestionRule,
+        })
+    }
+
+    nb, err := trainedClassifier()
+    if err != nil {
+        return nil, err
+    }
+
+    words := descriptionFeatures(t.Description)
+    if nb.Examples() < minTrainingExamples || nb.Known(words) == 0 {
+        return suggestions, nil
+    }
+
+    features := append(words, amountFeature(t.Amount))
+    if t.AccountID > 0 {
+        features = append(features, "account:"+strconv.Itoa(t.AccountID))
+    }
+
+    for _, prediction := range nb.Predict(features) {
+        if len(suggestions) >= limit {
+            break
+        }
+        category, ok := byID[prediction.Label]
+        if !ok || category.ID == ruled.CategoryID {
+            continue
+        }
+        suggestions = append(suggestions, CategorySuggestion{
+            CategoryID:   category.ID,
+            CategoryName: category.Name,
+            CategoryType: category.Type,
+            Confidence:   math.Round(prediction.Probability*1000) / 1000,
+            Source:       SuggestionHistory,
+        })
+    }
+
+    return suggestions, nil
+}
+
+// trainedClassifier returns the cached classifier, retraining it when
+// transactions have been added, changed or deleted since it was built
+func trainedClassifier() (*classifier.NaiveBayes, error) {
+    var count int
+    var lastChange time.Time
+    stmt := `SELECT COUNT(*), COALESCE(MAX(updated_at), 'epoch') FROM transactions WHERE deleted_at IS NULL`
+    if err := database.DB.QueryRow(stmt).Scan(&count, &lastChange); err != nil {
+        return nil, err
+    }
+    version := strconv.Itoa(count) + "/" + lastChange.UTC().Format(time.RFC3339Nano)
+
+    suggestionModel.Lock()
+    defer suggestionModel.Unlock()
+
+    if suggestionModel.classifier != nil && suggestionModel.version == version {
+        return suggestionModel.classifier, nil
+    }
+
+    rows, err := database.DB.Query(`
+        SELECT description, amount, COALESCE(account_id, 0), category_id
+        FROM transactions
+        WHERE deleted_at IS NULL
+        ORDER BY transaction_date DESC, id DESC
+        LIMIT $1`, maxTrainingExamples)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    nb := classifier.New()
+    for rows.Next() {
+        var description string
+        var amount float64
+        var accountID, categoryID int
+        if err := rows.Scan(&description, &amount, &accountID, &categoryID); err != nil {
+            return nil, err
+        }
+
+        features := append(descriptionFeatures(description), amountFeature(amount))
+        if accountID > 0 {
+            features = append(features, "account:"+strconv.Itoa(accountID))
+        }
+        nb.Train(categoryID, features)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    suggestionModel.classifier = nb
+    suggestionModel.version = version
+    return nb, nil
+}
+
+// descriptionFeatures splits a description into lower-case words, skipping
+// single characters and bare numbers such as dates and reference codes
+func descriptionFeatures(description string) []string {
+    var features []string
+    seen := map[string]bool{}
+
+    words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+    for _, word := range words {
+        if len([]rune(word)) < 2 || seen[word] {
+            continue
+        }
+        if strings.IndexFunc(word, unicode.IsLetter) < 0 {
+            continue
+        }
+        seen[word] = true
+        features = append(features, "word:"+word)
+    }
+    return features
+}
+
+// amountFeature buckets an amount by order of magnitude (powers of two), so
+// a $4 coffee and a $1,200 rent payment look different but $4 and $5 do not
+func amountFeature(amount float64) string {
+    if amount < 1 {
+        return "amount:0"
+    }
+    return "amount:" + strconv.Itoa(int(math.Log2(amount))+1)
+}
@@ -1,131 +1,432 @@
 package models
 
 import (
+    "database/sql"
     "fmt"
     "strconv"
+    "strings"
     "time"
     
+    "github.com/lib/pq"
+    
     "github.com/bryan/finance-tracker/internal/database"
+    "github.com/bryan/finance-tracker/internal/locale"
     "github.com/bryan/finance-tracker/internal/validator"
 )
 
 type Transaction struct {
-    ID              int       `json:"id"`
-    Amount          float64   `json:"amount"`
-    Description     string    `json:"description"`
-    CategoryID      int       `json:"category_id"`
-    CategoryName    string    `json:"category_name,omitempty"` // Used in joins
-    CategoryType    string    `json:"category_type,omitempty"` // Used in joins
-    TransactionDate time.Time `json:"transaction_date"`
-    CreatedAt       time.Time `json:"created_at"`
-    UpdatedAt       time.Time `json:"updated_at"`
+    ID              int        `json:"id"`
+    Amount          float64    `json:"amount"`
+    Currency        string     `json:"currency"` // ISO 4217 code; the account's currency when there is an account
+    Description     string     `json:"description"`
+    CategoryID      int        `json:"category_id"`
+    CategoryName    string     `json:"category_name,omitempty"` // Used in joins
+    CategoryType    string     `json:"category_type,omitempty"` // Used in joins
+    AccountID       int        `json:"account_id,omitempty"`    // Zero when not tied to an account
+    AccountName     string     `json:"account_name,omitempty"`  // Used in joins
+    PayeeID         int        `json:"payee_id,omitempty"`      // Zero when no payee matched
+    PayeeName       string     `json:"payee_name,omitempty"`    // Used in joins
+    Tags            []string   `json:"tags"`
+    TransactionDate time.Time  `json:"transaction_date"`
+    CreatedAt       time.Time  `json:"created_at"`
+    UpdatedAt       time.Time  `json:"updated_at"`
+    DeletedAt       *time.Time `json:"deleted_at,omitempty"` // Set while the transaction is in the trash
 }
 
 // TransactionFilter represents options for filtering transactions
 type TransactionFilter struct {
     CategoryID      int
     CategoryType    string
+    Tag             string
+    AccountID       int
+    PayeeID         int
     StartDate       time.Time
     EndDate         time.Time
+    Range           string // Preset the dates came from, such as last_month
     SortBy          string
     SortDirection   string
 }
 
-// Create adds a new transaction to the database
-func (t *Transaction) Create() error {
+// transactionColumns is the column list used by every transaction query;
+// scanTransaction reads a row in this order
+const transactionColumns = `
+        t.id, t.amount, t.currency, t.description, t.category_id, c.name, c.type,
+        COALESCE(t.account_id, 0), COALESCE(a.name, ''), COALESCE(t.payee_id, 0), COALESCE(p.name, ''),
+        t.tags, t.transaction_date, t.created_at, t.updated_at, t.deleted_at`
+
+// transactionJoins adds the category, account and payee names to transactionColumns
+const transactionJoins = `
+        FROM transactions t
+        JOIN categories c ON t.category_id = c.id
+        LEFT JOIN accounts a ON t.account_id = a.id
+        LEFT JOIN payees p ON t.payee_id = p.id`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanTransaction(row rowScanner, transaction *Transaction) error {
+    return row.Scan(
+        &transaction.ID, 
+        &transaction.Amount, 
+        &transaction.Currency,
+        &transaction.Description, 
+        &transaction.CategoryID,
+        &transaction.CategoryName,
+        &transaction.CategoryType,
+        &transaction.AccountID,
+        &transaction.AccountName,
+        &transaction.PayeeID,
+        &transaction.PayeeName,
+        pq.Array(&transaction.Tags),
+        &transaction.TransactionDate, 
+        &transaction.CreatedAt, 
+        &transaction.UpdatedAt,
+        &transaction.DeletedAt,
+    )
+}
+
+// auditSnapshot returns the fields of t recorded in the audit log
+func (t Transaction) auditSnapshot() map[string]interface{} {
+    return map[string]interface{}{
+        "amount":           t.Amount,
+        "currency":         t.Currency,
+        "description":      t.Description,
+        "category_id":      t.CategoryID,
+        "category":         t.CategoryName,
+        "account":          t.AccountName,
+        "payee":            t.PayeeName,
+        "tags":             t.Tags,
+        "transaction_date": t.TransactionDate.Format("2006-01-02"),
+    }
+}
+
+// withTx runs fn inside a database transaction, committing if it succeeds
+// and rolling back otherwise
+func withTx(fn func(tx *sql.Tx) error) error {
+    tx, err := database.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    if err := fn(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// Create adds a new transaction to the database and records it in the
+// audit log on behalf of actor
+func (t *Transaction) Create(actor string) error {
     stmt := `
-        INSERT INTO transactions (amount, description, category_id, transaction_date) 
-        VALUES ($1, $2, $3, $4)
-        RETURNING id, created_at, updated_at`
+        INSERT INTO transactions (amount, description, category_id, transaction_date, tags, account_id, payee_id, currency) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE((SELECT currency FROM accounts WHERE id = $6), $8))
+        RETURNING id, currency, created_at, updated_at`
 
-    return database.DB.QueryRow(
-        stmt, t.Amount, t.Description, t.CategoryID, t.TransactionDate,
-    ).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+    err := withTx(func(tx *sql.Tx) error {
+        // Link the payee from the description unless one was chosen
+        if t.PayeeID == 0 {
+            payeeID, err := matchPayee(tx, t.Description)
+            if err != nil {
+                return err
+            }
+            t.PayeeID = payeeID
+        }
+        
+        err := tx.QueryRow(
+            stmt, t.Amount, t.Description, t.CategoryID, t.TransactionDate, pq.Array(t.tagList()), nullID(t.AccountID), nullID(t.PayeeID), t.Currency,
+        ).Scan(&t.ID, &t.Currency, &t.CreatedAt, &t.UpdatedAt)
+        if err != nil {
+            return err
+        }
+        
+        after, err := getTransactionByID(tx, t.ID, false)
+        if err != nil {
+            return err
+        }
+        
+        return recordAudit(tx, EntityTransaction, t.ID, ActionCreate, nil, after.auditSnapshot(), actor)
+    })
+    return dbError(err)
 }
 
-// Update updates an existing transaction in the database
-func (t *Transaction) Update() error {
+// Update updates an existing transaction in the database and records the
+// before and after values in the audit log
+func (t *Transaction) Update(actor string) error {
     stmt := `
         UPDATE transactions 
-        SET amount = $1, description = $2, category_id = $3, transaction_date = $4, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $5
-        RETURNING updated_at`
+        SET amount = $1, description = $2, category_id = $3, transaction_date = $4, tags = $5, account_id = $6, payee_id = $7,
+            currency = COALESCE((SELECT currency FROM accounts WHERE id = $6), $9), updated_at = CURRENT_TIMESTAMP
+        WHERE id = $8 AND deleted_at IS NULL
+        RETURNING currency, updated_at`
+
+    err := withTx(func(tx *sql.Tx) error {
+        before, err := getTransactionByID(tx, t.ID, true)
+        if err != nil {
+            return err
+        }
+        
+        // Only look the payee up again when the description changed, so a
+        // payee can be cleared by hand
+        if t.PayeeID == 0 && t.Description != before.Description {
+            if t.PayeeID, err = matchPayee(tx, t.Description); err != nil {
+                return err
+            }
+        }
+        
+        err = tx.QueryRow(
+            stmt, t.Amount, t.Description, t.CategoryID, t.TransactionDate, pq.Array(t.tagList()), nullID(t.AccountID), nullID(t.PayeeID), t.ID, t.Currency,
+        ).Scan(&t.Currency, &t.UpdatedAt)
+        if err != nil {
+            return err
+        }
+        
+        after, err := getTransactionByID(tx, t.ID, false)
+        if err != nil {
+            return err
+        }
+        
+        return recordAudit(tx, EntityTransaction, t.ID, ActionUpdate, before.auditSnapshot(), after.auditSnapshot(), actor)
+    })
+    return dbError(err)
+}
+
+// Delete moves a transaction to the trash. It stays restorable until it is
+// purged. It returns ErrNotFound if no live transaction has the given ID.
+func (t *Transaction) Delete(actor string) error {
+    stmt := `UPDATE transactions SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL`
 
-    return database.DB.QueryRow(
-        stmt, t.Amount, t.Description, t.CategoryID, t.TransactionDate, t.ID,
-    ).Scan(&t.UpdatedAt)
+    err := withTx(func(tx *sql.Tx) error {
+        before, err := getTransactionByID(tx, t.ID, true)
+        if err != nil {
+            return err
+        }
+        
+        if _, err := tx.Exec(stmt, t.ID); err != nil {
+            return err
+        }
+        
+        return recordAudit(tx, EntityTransaction, t.ID, ActionDelete, before.auditSnapshot(), nil, actor)
+    })
+    return dbError(err)
 }
 
-// Delete removes a transaction from the database
-func (t *Transaction) Delete() error {
-    stmt := `DELETE FROM transactions WHERE id = $1`
-    _, err := database.DB.Exec(stmt, t.ID)
-    return err
+// Restore takes a transaction out of the trash. It returns ErrNotFound if
+// the transaction is not in the trash.
+func (t *Transaction) Restore(actor string) error {
+    stmt := `UPDATE transactions SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NOT NULL`
+
+    err := withTx(func(tx *sql.Tx) error {
+        deleted, err := getDeletedTransactionByID(tx, t.ID, true)
+        if err != nil {
+            return err
+        }
+        
+        if _, err := tx.Exec(stmt, t.ID); err != nil {
+            return err
+        }
+        
+        return recordAudit(tx, EntityTransaction, t.ID, ActionRestore, nil, deleted.auditSnapshot(), actor)
+    })
+    return dbError(err)
+}
+
+// Purge permanently removes a transaction that is in the trash, along with
+// its attachments. It returns ErrNotFound if the transaction is not in the trash.
+func (t *Transaction) Purge(actor string) error {
+    var blobs []string
+    err := withTx(func(tx *sql.Tx) error {
+        deleted, err := getDeletedTransactionByID(tx, t.ID, true)
+        if err != nil {
+            return err
+        }
+        blobs, err = purgeTransaction(tx, deleted, actor)
+        return err
+    })
+    if err != nil {
+        return dbError(err)
+    }
+    
+    deleteBlobs(blobs)
+    return nil
 }
 
-// GetTransactionByID retrieves a transaction by its ID
+// PurgeDeletedBefore permanently removes every transaction that was moved to
+// the trash before cutoff and returns how many were removed
+func PurgeDeletedBefore(cutoff time.Time, actor string) (int, error) {
+    stmt := `
+        SELECT` + transactionColumns + transactionJoins + `
+        WHERE t.deleted_at < $1
+        FOR UPDATE OF t`
+
+    purged := 0
+    var blobs []string
+    err := withTx(func(tx *sql.Tx) error {
+        rows, err := tx.Query(stmt, cutoff)
+        if err != nil {
+            return err
+        }
+        
+        var expired []Transaction
+        for rows.Next() {
+            var transaction Transaction
+            if err := scanTransaction(rows, &transaction); err != nil {
+                rows.Close()
+                return err
+            }
+            expired = append(expired, transaction)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return err
+        }
+        
+        for _, transaction := range expired {
+            keys, err := purgeTransaction(tx, transaction, actor)
+            if err != nil {
+                return err
+            }
+            blobs = append(blobs, keys...)
+        }
+        purged = len(expired)
+        return nil
+    })
+    if err != nil {
+        return 0, dbError(err)
+    }
+    
+    deleteBlobs(blobs)
+    return purged, nil
+}
+
+// purgeTransaction deletes a trashed transaction; its attachment rows go
+// with it. It returns the attachment files to delete once the database
+// transaction commits.
+func purgeTransaction(tx *sql.Tx, transaction Transaction, actor string) ([]string, error) {
+    blobs, err := attachmentBlobKeys(tx, transaction.ID)
+    if err != nil {
+        return nil, err
+    }
+    
+    stmt := `DELETE FROM transactions WHERE id = $1 AND deleted_at IS NOT NULL`
+    if _, err := tx.Exec(stmt, transaction.ID); err != nil {
+        return nil, err
+    }
+    return blobs, recordAudit(tx, EntityTransaction, transaction.ID, ActionPurge, transaction.auditSnapshot(), nil, actor)
+}
+
+// GetTransactionByID retrieves a transaction by its ID. Transactions in the
+// trash are reported as ErrNotFound.
 func GetTransactionByID(id int) (Transaction, error) {
+    transaction, err := getTransactionByID(database.DB, id, false)
+    return transaction, dbError(err)
+}
+
+// getTransactionByID loads a live transaction with its category, optionally
+// locking the row until the surrounding database transaction ends
+func getTransactionByID(q queryer, id int, forUpdate bool) (Transaction, error) {
+    return loadTransaction(q, "t.id = $1 AND t.deleted_at IS NULL", id, forUpdate)
+}
+
+// getDeletedTransactionByID loads a transaction that is in the trash
+func getDeletedTransactionByID(q queryer, id int, forUpdate bool) (Transaction, error) {
+    return loadTransaction(q, "t.id = $1 AND t.deleted_at IS NOT NULL", id, forUpdate)
+}
+
+func loadTransaction(q queryer, where string, id int, forUpdate bool) (Transaction, error) {
     var transaction Transaction
     
     stmt := `
-        SELECT t.id, t.amount, t.description, t.category_id, c.name, c.type, t.transaction_date, t.created_at, t.updated_at
-        FROM transactions t
-        JOIN categories c ON t.category_id = c.id
-        WHERE t.id = $1`
+        SELECT` + transactionColumns + transactionJoins + `
+        WHERE ` + where
+    if forUpdate {
+        stmt += " FOR UPDATE OF t"
+    }
 
-    err := database.DB.QueryRow(stmt, id).Scan(
-        &transaction.ID, 
-        &transaction.Amount, 
-        &transaction.Description, 
-        &transaction.CategoryID,
-        &transaction.CategoryName,
-        &transaction.CategoryType,
-        &transaction.TransactionDate, 
-        &transaction.CreatedAt, 
-        &transaction.UpdatedAt,
-    )
-    
+    err := scanTransaction(q.QueryRow(stmt, id), &transaction)
     return transaction, err
 }
 
-// GetTransactions retrieves transactions with optional filtering
-func GetTransactions(filter TransactionFilter) ([]Transaction, error) {
-    // Start with the base query
-    query := `
-        SELECT t.id, t.amount, t.description, t.category_id, c.name, c.type, t.transaction_date, t.created_at, t.updated_at
-        FROM transactions t
-        JOIN categories c ON t.category_id = c.id
-        WHERE 1=1`
-    
-    // Build args array for the query parameters
-    args := []interface{}{}
-    paramCount := 0
+// GetDeletedTransactions retrieves the transactions in the trash, most
+// recently deleted first
+func GetDeletedTransactions() ([]Transaction, error) {
+    stmt := `
+        SELECT` + transactionColumns + transactionJoins + `
+        WHERE t.deleted_at IS NOT NULL
+        ORDER BY t.deleted_at DESC`
 
-    // Add filter conditions if provided
-    if filter.CategoryID > 0 {
-        paramCount++
-        query += fmt.Sprintf(" AND t.category_id = $%d", paramCount)
-        args = append(args, filter.CategoryID)
+    rows, err := database.DB.Query(stmt)
+    if err != nil {
+        return nil, err
     }
+    defer rows.Close()
 
-    if filter.CategoryType != "" {
-        paramCount++
-        query += fmt.Sprintf(" AND c.type = $%d", paramCount)
-        args = append(args, filter.CategoryType)
+    var transactions []Transaction
+
+    for rows.Next() {
+        var transaction Transaction
+        if err := scanTransaction(rows, &transaction); err != nil {
+            return nil, err
+        }
+        transactions = append(transactions, transaction)
     }
 
-    if !filter.StartDate.IsZero() {
-        paramCount++
-        query += fmt.Sprintf(" AND t.transaction_date >= $%d", paramCount)
-        args = append(args, filter.StartDate)
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return transactions, nil
+}
+
+// where returns the filter's conditions, each starting with " AND ", for a
+// query joining transactions t and categories c. Their values are appended
+// to args and numbered after the parameters already in it; dates are
+// inclusive and sorting is ignored.
+func (filter TransactionFilter) where(args []interface{}) (string, []interface{}) {
+    var conditions strings.Builder
+
+    add := func(condition string, value interface{}) {
+        args = append(args, value)
+        fmt.Fprintf(&conditions, condition, len(args))
     }
 
+    if filter.CategoryID > 0 {
+        add(" AND t.category_id = $%d", filter.CategoryID)
+    }
+    if filter.CategoryType != "" {
+        add(" AND c.type = $%d", filter.CategoryType)
+    }
+    if filter.AccountID > 0 {
+        add(" AND t.account_id = $%d", filter.AccountID)
+    }
+    if filter.PayeeID > 0 {
+        add(" AND t.payee_id = $%d", filter.PayeeID)
+    }
+    if filter.Tag != "" {
+        add(" AND $%d = ANY(t.tags)", filter.Tag)
+    }
+    if !filter.StartDate.IsZero() {
+        add(" AND t.transaction_date >= $%d", filter.StartDate)
+    }
     if !filter.EndDate.IsZero() {
-        paramCount++
-        query += fmt.Sprintf(" AND t.transaction_date <= $%d", paramCount)
-        args = append(args, filter.EndDate)
+        add(" AND t.transaction_date <= $%d", filter.EndDate)
     }
 
+    return conditions.String(), args
+}
+
+// GetTransactions retrieves transactions with optional filtering
+func GetTransactions(filter TransactionFilter) ([]Transaction, error) {
+    // Start with the base query
+    query := `
+        SELECT` + transactionColumns + transactionJoins + `
+        WHERE t.deleted_at IS NULL`
+    
+    conditions, args := filter.where(nil)
+    query += conditions
+
     // Add sorting
     sortBy := "t.transaction_date"
     if filter.SortBy != "" {
@@ -157,17 +458,7 @@ func GetTransactions(filter TransactionFilter) ([]Transaction, error) {
 
     for rows.Next() {
         var transaction Transaction
-        if err := rows.Scan(
-            &transaction.ID, 
-            &transaction.Amount, 
-            &transaction.Description, 
-            &transaction.CategoryID,
-            &transaction.CategoryName,
-            &transaction.CategoryType,
-            &transaction.TransactionDate, 
-            &transaction.CreatedAt, 
-            &transaction.UpdatedAt,
-        ); err != nil {
+        if err := scanTransaction(rows, &transaction); err != nil {
             return nil, err
         }
         transactions = append(transactions, transaction)
@@ -180,20 +471,24 @@ func GetTransactions(filter TransactionFilter) ([]Transaction, error) {
     return transactions, nil
 }
 
-// GetSummary retrieves summary statistics for the transactions
+// GetSummary retrieves summary statistics for the transactions, in the base
+// currency. "unconverted" counts the transactions left out because there is
+// no exchange rate for their currency and date.
 func GetSummary(startDate, endDate time.Time) (map[string]float64, error) {
     summary := map[string]float64{
         "totalIncome":  0,
         "totalExpense": 0,
         "balance":      0,
+        "unconverted":  0,
     }
 
     // Query for total income and expenses
     stmt := `
-        SELECT c.type, SUM(t.amount) as total
+        SELECT c.type, COALESCE(SUM(` + baseAmount + `), 0) as total,
+            COUNT(*) FILTER (WHERE ` + baseAmount + ` IS NULL)
         FROM transactions t
         JOIN categories c ON t.category_id = c.id
-        WHERE t.transaction_date BETWEEN $1 AND $2
+        WHERE t.transaction_date BETWEEN $1 AND $2 AND t.deleted_at IS NULL
         GROUP BY c.type`
 
     rows, err := database.DB.Query(stmt, startDate, endDate)
@@ -204,12 +499,13 @@ func GetSummary(startDate, endDate time.Time) (map[string]float64, error) {
 
     for rows.Next() {
         var categoryType string
-        var total float64
+        var total, unconverted float64
 
-        err := rows.Scan(&categoryType, &total)
+        err := rows.Scan(&categoryType, &total, &unconverted)
         if err != nil {
             return summary, err
         }
+        summary["unconverted"] += unconverted
 
         if categoryType == "income" {
             summary["totalIncome"] = total
@@ -226,6 +522,15 @@ func GetSummary(startDate, endDate time.Time) (map[string]float64, error) {
     return summary, nil
 }
 
+// CountTransactionsCreatedSince returns how many transactions were entered at
+// or after the given time
+func CountTransactionsCreatedSince(since time.Time) (int, error) {
+    var count int
+    stmt := `SELECT COUNT(*) FROM transactions WHERE created_at >= $1 AND deleted_at IS NULL`
+    err := database.DB.QueryRow(stmt, since).Scan(&count)
+    return count, err
+}
+
 // ValidateTransaction validates transaction data
 func ValidateTransaction(v *validator.Validator, transaction *Transaction) {
     // Check amount is greater than zero
@@ -241,10 +546,57 @@ func ValidateTransaction(v *validator.Validator, transaction *Transaction) {
     v.Check(!transaction.TransactionDate.IsZero(), "transaction_date", "Transaction date is required")
     
     // Check transaction date is not in the future
-    v.Check(transaction.TransactionDate.Before(time.Now().AddDate(0, 0, 1)), "transaction_date", "Transaction date cannot be in the future")
+    v.Check(!dateOnly(transaction.TransactionDate).After(Today()), "transaction_date", "Transaction date cannot be in the future")
+    
+    // Check currency
+    v.Check(locale.IsCurrency(transaction.Currency), "currency", "Please enter a currency code such as USD or EUR")
+    
+    // Check tags
+    v.Check(len(transaction.Tags) <= maxTags, "tags", fmt.Sprintf("A transaction can have at most %d tags", maxTags))
+    for _, tag := range transaction.Tags {
+        v.Check(validator.MaxLength(tag, maxTagLength), "tags", fmt.Sprintf("Tags cannot exceed %d characters", maxTagLength))
+    }
 }
 
-// ParseTransactionForm parses the form data to create a Transaction object
+// Limits on the tags of a single transaction
+const (
+    maxTags      = 20
+    maxTagLength = 50
+)
+
+// ParseTags splits a comma-separated list into lowercase tags, dropping
+// blanks and duplicates
+func ParseTags(value string) []string {
+    tags := []string{}
+    seen := map[string]bool{}
+    
+    for _, tag := range strings.Split(value, ",") {
+        tag = strings.ToLower(strings.TrimSpace(tag))
+        if tag == "" || seen[tag] {
+            continue
+        }
+        seen[tag] = true
+        tags = append(tags, tag)
+    }
+    
+    return tags
+}
+
+// TagString joins the tags for display in a form field
+func (t Transaction) TagString() string {
+    return strings.Join(t.Tags, ", ")
+}
+
+// tagList never returns nil so the NOT NULL tags column gets an empty array
+func (t Transaction) tagList() []string {
+    if t.Tags == nil {
+        return []string{}
+    }
+    return t.Tags
+}
+
+// ParseTransactionForm parses the form data to create a Transaction object.
+// Malformed values are reported as a *ValidationError.
 func ParseTransactionForm(form map[string]string) (*Transaction, error) {
     transaction := &Transaction{}
     
@@ -252,7 +604,7 @@ func ParseTransactionForm(form map[string]string) (*Transaction, error) {
     if form["amount"] != "" {
         amount, err := strconv.ParseFloat(form["amount"], 64)
         if err != nil {
-            return nil, fmt.Errorf("invalid amount format")
+            return nil, &ValidationError{Field: "amount", Message: "Amount must be a number"}
         }
         transaction.Amount = amount
     }
@@ -260,11 +612,39 @@ func ParseTransactionForm(form map[string]string) (*Transaction, error) {
     // Parse description
     transaction.Description = form["description"]
     
+    // Parse tags
+    transaction.Tags = ParseTags(form["tags"])
+    
+    // Parse currency; empty means the base currency. Transactions in an
+    // account always take the account's currency when saved.
+    transaction.Currency = ParseCurrency(form["currency"])
+    if transaction.Currency == "" {
+        transaction.Currency = GetPreferences().Currency
+    }
+    
+    // Parse account ID; empty means no account
+    if form["account_id"] != "" {
+        accountID, err := strconv.Atoi(form["account_id"])
+        if err != nil {
+            return nil, &ValidationError{Field: "account_id", Message: "Please select a valid account"}
+        }
+        transaction.AccountID = accountID
+    }
+    
+    // Parse payee ID; empty means detect it from the description
+    if form["payee_id"] != "" {
+        payeeID, err := strconv.Atoi(form["payee_id"])
+        if err != nil {
+            return nil, &ValidationError{Field: "payee_id", Message: "Please select a valid payee"}
+        }
+        transaction.PayeeID = payeeID
+    }
+    
     // Parse category ID
     if form["category_id"] != "" {
         categoryID, err := strconv.Atoi(form["category_id"])
         if err != nil {
-            return nil, fmt.Errorf("invalid category ID format")
+            return nil, &ValidationError{Field: "category_id", Message: "Please select a valid category"}
         }
         transaction.CategoryID = categoryID
     }
@@ -273,7 +653,7 @@ func ParseTransactionForm(form map[string]string) (*Transaction, error) {
     if form["transaction_date"] != "" {
         date, err := time.Parse("2006-01-02", form["transaction_date"])
         if err != nil {
-            return nil, fmt.Errorf("invalid date format. Use YYYY-MM-DD")
+            return nil, &ValidationError{Field: "transaction_date", Message: "Invalid date format. Use YYYY-MM-DD"}
         }
         transaction.TransactionDate = date
     }
@@ -282,7 +662,7 @@ func ParseTransactionForm(form map[string]string) (*Transaction, error) {
     if form["id"] != "" {
         id, err := strconv.Atoi(form["id"])
         if err != nil {
-            return nil, fmt.Errorf("invalid ID format")
+            return nil, &ValidationError{Field: "id", Message: "Invalid transaction ID"}
         }
         transaction.ID = id
     }
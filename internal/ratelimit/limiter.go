@@ -0,0 +1,121 @@
+// Package ratelimit provides in-memory token buckets for throttling clients
+// by key, such as their IP address.
+package ratelimit
+
+import (
+    "context"
+    "math"
+    "sync"
+    "time"
+)
+
+// Limiter is a set of token buckets, one per key. Each bucket holds up to
+// burst tokens and refills at rate tokens per second.
+type Limiter struct {
+    rate  float64
+    burst float64
+
+    mu      sync.Mutex
+    buckets map[string]*bucket
+}
+
+type bucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// Result describes the state of a bucket after a call to Allow
+type Result struct {
+    Allowed    bool
+    Limit      int           // bucket capacity
+    Remaining  int           // whole tokens left
+    Reset      time.Duration // until the bucket is full again
+    RetryAfter time.Duration // until the next request is allowed; zero if allowed
+}
+
+// New returns a limiter allowing requests per window with the given burst
+func New(requests int, window time.Duration, burst int) *Limiter {
+    if burst < 1 {
+        burst = 1
+    }
+    return &Limiter{
+        rate:    float64(requests) / window.Seconds(),
+        burst:   float64(burst),
+        buckets: make(map[string]*bucket),
+    }
+}
+
+// Allow takes one token from the bucket for key if one is available
+func (l *Limiter) Allow(key string) Result {
+    now := time.Now()
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    b, ok := l.buckets[key]
+    if !ok {
+        b = &bucket{tokens: l.burst, last: now}
+        l.buckets[key] = b
+    }
+    l.refill(b, now)
+
+    result := Result{Limit: int(l.burst)}
+    if b.tokens >= 1 {
+        b.tokens--
+        result.Allowed = true
+    } else {
+        result.RetryAfter = l.durationFor(1 - b.tokens)
+    }
+
+    result.Remaining = int(math.Floor(b.tokens))
+    result.Reset = l.durationFor(l.burst - b.tokens)
+    return result
+}
+
+// Sweep forgets buckets that have refilled completely; they are
+// indistinguishable from new ones
+func (l *Limiter) Sweep() {
+    now := time.Now()
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    for key, b := range l.buckets {
+        l.refill(b, now)
+        if b.tokens >= l.burst {
+            delete(l.buckets, key)
+        }
+    }
+}
+
+// Run calls Sweep every interval until ctx is cancelled
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+    runEvery(ctx, interval, l.Sweep)
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+    elapsed := now.Sub(b.last).Seconds()
+    b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+    b.last = now
+}
+
+func (l *Limiter) durationFor(tokens float64) time.Duration {
+    if tokens <= 0 || l.rate <= 0 {
+        return 0
+    }
+    return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func runEvery(ctx context.Context, interval time.Duration, fn func()) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            fn()
+        }
+    }
+}
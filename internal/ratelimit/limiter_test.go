@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+    "testing"
+    "time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+    tests := []struct {
+        name          string
+        requests      int
+        burst         int
+        calls         int
+        wantAllowed   int
+        wantRemaining int
+    }{
+        {"within burst", 60, 5, 3, 3, 2},
+        {"exactly burst", 60, 5, 5, 5, 0},
+        {"over burst", 60, 5, 8, 5, 0},
+        {"burst below one is one", 60, 0, 3, 1, 0},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            // A window of an hour keeps refills negligible during the test
+            l := New(tt.requests, time.Hour, tt.burst)
+
+            allowed := 0
+            var last Result
+            for i := 0; i < tt.calls; i++ {
+                last = l.Allow("client")
+                if last.Allowed {
+                    allowed++
+                }
+            }
+
+            if allowed != tt.wantAllowed {
+                t.Errorf("allowed %d requests, want %d", allowed, tt.wantAllowed)
+            }
+            if last.Remaining != tt.wantRemaining {
+                t.Errorf("Remaining = %d, want %d", last.Remaining, tt.wantRemaining)
+            }
+            if !last.Allowed && last.RetryAfter <= 0 {
+                t.Errorf("RetryAfter = %v for a denied request, want a wait", last.RetryAfter)
+            }
+            if last.Allowed && last.RetryAfter != 0 {
+                t.Errorf("RetryAfter = %v for an allowed request, want 0", last.RetryAfter)
+            }
+        })
+    }
+}
+
+func TestLimiterKeysAreSeparate(t *testing.T) {
+    l := New(60, time.Hour, 1)
+
+    if !l.Allow("a").Allowed {
+        t.Fatal("first request for a was denied")
+    }
+    if l.Allow("a").Allowed {
+        t.Error("second request for a was allowed past the burst")
+    }
+    if !l.Allow("b").Allowed {
+        t.Error("request for b was denied by a's bucket")
+    }
+}
+
+func TestLimiterRefills(t *testing.T) {
+    // 1000 requests per second refill a token every millisecond
+    l := New(1000, time.Second, 1)
+
+    l.Allow("client")
+    if l.Allow("client").Allowed {
+        t.Fatal("second immediate request was allowed")
+    }
+    time.Sleep(5 * time.Millisecond)
+    if !l.Allow("client").Allowed {
+        t.Error("request after the refill interval was denied")
+    }
+}
+
+func TestLimiterSweep(t *testing.T) {
+    l := New(60, time.Hour, 2)
+    l.Allow("used")
+    l.buckets["full"] = &bucket{tokens: 2, last: time.Now()}
+
+    l.Sweep()
+
+    if _, ok := l.buckets["full"]; ok {
+        t.Error("Sweep kept a full bucket")
+    }
+    if _, ok := l.buckets["used"]; !ok {
+        t.Error("Sweep dropped a bucket that is not full")
+    }
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// Local stores blobs as files below a root directory
+type Local struct {
+    root string
+}
+
+// NewLocal returns a store rooted at dir, creating the directory if needed
+func NewLocal(dir string) (*Local, error) {
+    root, err := filepath.Abs(dir)
+    if err != nil {
+        return nil, fmt.Errorf("storage: %v", err)
+    }
+    if err := os.MkdirAll(root, 0o750); err != nil {
+        return nil, fmt.Errorf("storage: creating %s: %v", root, err)
+    }
+    return &Local{root: root}, nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so a
+// failed upload never leaves a partial blob under key
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+    path, err := l.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+        return fmt.Errorf("storage: %v", err)
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+    if err != nil {
+        return fmt.Errorf("storage: %v", err)
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := io.Copy(tmp, r); err != nil {
+        tmp.Close()
+        return fmt.Errorf("storage: writing %s: %v", key, err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("storage: writing %s: %v", key, err)
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        return fmt.Errorf("storage: %v", err)
+    }
+    return nil
+}
+
+// Open returns a reader for the blob stored under key
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+    path, err := l.path(key)
+    if err != nil {
+        return nil, err
+    }
+
+    f, err := os.Open(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, ErrNotExist
+    }
+    if err != nil {
+        return nil, fmt.Errorf("storage: %v", err)
+    }
+    return f, nil
+}
+
+// Delete removes the blob stored under key. Deleting a missing blob is not
+// an error.
+func (l *Local) Delete(ctx context.Context, key string) error {
+    path, err := l.path(key)
+    if err != nil {
+        return err
+    }
+
+    if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return fmt.Errorf("storage: %v", err)
+    }
+    return nil
+}
+
+// path maps a key to a file below the root, rejecting keys that could
+// escape it
+func (l *Local) path(key string) (string, error) {
+    if !validKey(key) {
+        return "", fmt.Errorf("storage: invalid key %q", key)
+    }
+    return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func validKey(key string) bool {
+    if key == "" || len(key) > 255 {
+        return false
+    }
+    for _, part := range strings.Split(key, "/") {
+        if part == "" || part == "." || part == ".." || strings.HasPrefix(part, ".") {
+            return false
+        }
+        for _, r := range part {
+            ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.'
+            if !ok {
+                return false
+            }
+        }
+    }
+    return true
+}
@@ -0,0 +1,20 @@
+// Package storage stores opaque blobs such as receipt attachments under
+// string keys. Store is the extension point; Local keeps blobs on disk.
+package storage
+
+import (
+    "context"
+    "errors"
+    "io"
+)
+
+// ErrNotExist is returned when no blob is stored under a key
+var ErrNotExist = errors.New("storage: blob does not exist")
+
+// Store saves, reads and removes blobs. Keys are slash-separated paths made
+// of letters, digits, '-', '_' and '.'; callers generate them.
+type Store interface {
+    Put(ctx context.Context, key string, r io.Reader) error
+    Open(ctx context.Context, key string) (io.ReadCloser, error)
+    Delete(ctx context.Context, key string) error
+}
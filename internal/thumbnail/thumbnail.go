@@ -0,0 +1,120 @@
+// Package thumbnail scales down JPEG, PNG and GIF images for previews
+package thumbnail
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/jpeg"
+
+    // Register the decoders image.Decode needs
+    _ "image/gif"
+    _ "image/png"
+)
+
+// ContentType is the media type of every generated thumbnail
+const ContentType = "image/jpeg"
+
+// maxSourcePixels refuses to decode images that would use too much memory:
+// about 20 megapixels, or 80 MB once decoded to RGBA
+const maxSourcePixels = 20_000_000
+
+// ErrTooLarge is returned for images with more than maxSourcePixels pixels
+var ErrTooLarge = errors.New("thumbnail: image dimensions too large")
+
+// Generate decodes an image and returns a JPEG that fits within size by size
+// pixels, keeping the aspect ratio. Images already small enough are
+// re-encoded at their original size.
+func Generate(data []byte, size int) ([]byte, error) {
+    config, _, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil {
+        return nil, fmt.Errorf("thumbnail: %v", err)
+    }
+    if config.Width*config.Height > maxSourcePixels {
+        return nil, ErrTooLarge
+    }
+
+    src, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, fmt.Errorf("thumbnail: %v", err)
+    }
+
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, scale(src, size), &jpeg.Options{Quality: 80}); err != nil {
+        return nil, fmt.Errorf("thumbnail: %v", err)
+    }
+    return buf.Bytes(), nil
+}
+
+// scale shrinks src to fit within size by size using a box filter: each
+// destination pixel averages the source pixels it covers
+func scale(src image.Image, size int) image.Image {
+    bounds := src.Bounds()
+    w, h := bounds.Dx(), bounds.Dy()
+
+    dw, dh := w, h
+    if w > size || h > size {
+        if w >= h {
+            dw, dh = size, max(1, h*size/w)
+        } else {
+            dw, dh = max(1, w*size/h), size
+        }
+    }
+
+    at := pixelReader(src)
+    dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+    for y := 0; y < dh; y++ {
+        y0 := bounds.Min.Y + y*h/dh
+        y1 := max(y0+1, bounds.Min.Y+(y+1)*h/dh)
+        for x := 0; x < dw; x++ {
+            x0 := bounds.Min.X + x*w/dw
+            x1 := max(x0+1, bounds.Min.X+(x+1)*w/dw)
+
+            var r, g, b, a, n uint64
+            for sy := y0; sy < y1; sy++ {
+                for sx := x0; sx < x1; sx++ {
+                    cr, cg, cb, ca := at(sx, sy)
+                    r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+                    n++
+                }
+            }
+
+            // JPEG has no alpha; composite transparent pixels onto white
+            alpha := a / n
+            white := 0xffff - alpha
+            dst.SetRGBA64(x, y, color.RGBA64{
+                R: uint16(r/n + white),
+                G: uint16(g/n + white),
+                B: uint16(b/n + white),
+                A: 0xffff,
+            })
+        }
+    }
+    return dst
+}
+
+// pixelReader returns a function reporting the alpha-premultiplied colour of
+// a source pixel like color.Color.RGBA. JPEG photos and RGBA images are read
+// straight from their pixel buffers, since boxing every pixel through At
+// dominates the time spent scaling a large image.
+func pixelReader(src image.Image) func(x, y int) (r, g, b, a uint32) {
+    switch src := src.(type) {
+    case *image.YCbCr:
+        return func(x, y int) (uint32, uint32, uint32, uint32) {
+            yi, ci := src.YOffset(x, y), src.COffset(x, y)
+            r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+            return uint32(r) * 0x101, uint32(g) * 0x101, uint32(b) * 0x101, 0xffff
+        }
+    case *image.RGBA:
+        return func(x, y int) (uint32, uint32, uint32, uint32) {
+            p := src.Pix[src.PixOffset(x, y):]
+            return uint32(p[0]) * 0x101, uint32(p[1]) * 0x101, uint32(p[2]) * 0x101, uint32(p[3]) * 0x101
+        }
+    default:
+        return func(x, y int) (uint32, uint32, uint32, uint32) {
+            return src.At(x, y).RGBA()
+        }
+    }
+}
@@ -0,0 +1,64 @@
+package thumbnail
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "image/color"
+    "image/png"
+    "testing"
+)
+
+func TestPixelReader(t *testing.T) {
+    rgba := image.NewRGBA(image.Rect(2, 3, 6, 7))
+    ycbcr := image.NewYCbCr(image.Rect(2, 3, 6, 7), image.YCbCrSubsampleRatio420)
+    for y := 3; y < 7; y++ {
+        for x := 2; x < 6; x++ {
+            rgba.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 30), 200, 128})
+            ycbcr.Y[ycbcr.YOffset(x, y)] = uint8(x * y * 10)
+        }
+    }
+    for i := range ycbcr.Cb {
+        ycbcr.Cb[i], ycbcr.Cr[i] = uint8(60+i*20), uint8(200-i*30)
+    }
+
+    tests := []struct {
+        name string
+        src  image.Image
+    }{
+        {"rgba", rgba},
+        {"ycbcr", ycbcr},
+    }
+
+    for _, tt := range tests {
+        at := pixelReader(tt.src)
+        for y := 3; y < 7; y++ {
+            for x := 2; x < 6; x++ {
+                r, g, b, a := at(x, y)
+                wr, wg, wb, wa := tt.src.At(x, y).RGBA()
+                // color.YCbCr converts with more precision than the 8-bit
+                // fast path, so allow one 8-bit step of difference
+                if diff(r, wr) > 0x101 || diff(g, wg) > 0x101 || diff(b, wb) > 0x101 || a != wa {
+                    t.Errorf("%s at (%d, %d) = %d %d %d %d, want %d %d %d %d", tt.name, x, y, r, g, b, a, wr, wg, wb, wa)
+                }
+            }
+        }
+    }
+}
+
+func TestGenerateTooLarge(t *testing.T) {
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 5000, 5000))); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := Generate(buf.Bytes(), 200); !errors.Is(err, ErrTooLarge) {
+        t.Errorf("Generate(5000x5000) error = %v, want ErrTooLarge", err)
+    }
+}
+
+func diff(a, b uint32) uint32 {
+    if a > b {
+        return a - b
+    }
+    return b - a
+}
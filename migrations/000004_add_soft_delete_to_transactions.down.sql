@@ -0,0 +1,6 @@
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN ('create', 'update', 'delete')) NOT VALID;
+
+DROP INDEX IF EXISTS idx_transactions_deleted_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS deleted_at;
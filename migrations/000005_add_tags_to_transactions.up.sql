@@ -0,0 +1,4 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Create index for filtering by tag
+CREATE INDEX idx_transactions_tags ON transactions USING GIN (tags);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_account;
+ALTER TABLE transactions DROP COLUMN IF EXISTS account_id;
+DROP TABLE IF EXISTS accounts;
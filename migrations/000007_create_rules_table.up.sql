@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+
+    -- Conditions; a NULL or empty condition matches everything
+    description_match VARCHAR(20) NOT NULL DEFAULT '' CHECK (description_match IN ('', 'contains', 'regex')),
+    description_pattern TEXT NOT NULL DEFAULT '',
+    amount_min DECIMAL(12, 2),
+    amount_max DECIMAL(12, 2),
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+
+    -- Actions
+    set_category_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT,
+    add_tags TEXT[] NOT NULL DEFAULT '{}',
+    set_description TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for loading enabled rules in evaluation order
+CREATE INDEX idx_rules_priority ON rules(priority, id) WHERE enabled;
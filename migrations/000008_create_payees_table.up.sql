@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS payees (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Payee names are unique regardless of case
+CREATE UNIQUE INDEX idx_payees_name ON payees(LOWER(name));
+
+-- Aliases are normalized description prefixes ("amzn mktp us") that identify a payee
+CREATE TABLE IF NOT EXISTS payee_aliases (
+    id SERIAL PRIMARY KEY,
+    payee_id INTEGER NOT NULL REFERENCES payees(id) ON DELETE CASCADE,
+    alias VARCHAR(200) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payee_aliases_payee ON payee_aliases(payee_id);
+
+-- Existing transactions are linked once a matching payee is added
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS payee_id INTEGER REFERENCES payees(id) ON DELETE SET NULL;
+
+-- Create index for payee totals and history
+CREATE INDEX idx_transactions_payee ON transactions(payee_id);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS goals (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    target_amount DECIMAL(12, 2) NOT NULL CHECK (target_amount > 0),
+    start_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    target_date DATE,
+
+    -- Income into this account from start_date on counts toward the goal
+    account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Transactions linked to a goal by hand
+CREATE TABLE IF NOT EXISTS goal_contributions (
+    goal_id INTEGER NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (goal_id, transaction_id)
+);
+
+CREATE INDEX idx_goal_contributions_transaction ON goal_contributions(transaction_id);
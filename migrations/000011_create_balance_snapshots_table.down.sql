@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS balance_snapshots;
+
+UPDATE accounts SET type = 'other' WHERE type IN ('property', 'pension', 'loan', 'mortgage');
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_type_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_type_check
+    CHECK (type IN ('checking', 'savings', 'credit_card', 'cash', 'investment', 'other'));
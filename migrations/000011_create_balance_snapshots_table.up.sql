@@ -0,0 +1,17 @@
+-- Accounts for assets and debts that are tracked by balance rather than transactions
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_type_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_type_check
+    CHECK (type IN ('checking', 'savings', 'credit_card', 'cash', 'investment', 'property', 'pension', 'loan', 'mortgage', 'other'));
+
+-- A snapshot is the balance of an account at the end of a day, after that day's
+-- transactions. For credit cards, loans and mortgages it is the amount owed.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    as_of DATE NOT NULL,
+    balance DECIMAL(14, 2) NOT NULL,
+    note VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account_id, as_of)
+);
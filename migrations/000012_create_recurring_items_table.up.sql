@@ -0,0 +1,19 @@
+-- Scheduled income and bills used by the cash-flow forecast
+CREATE TABLE IF NOT EXISTS recurring_items (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE RESTRICT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('weekly', 'biweekly', 'monthly', 'quarterly', 'yearly')),
+
+    -- First occurrence; later ones follow from the frequency
+    start_date DATE NOT NULL,
+    end_date DATE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recurring_items_account ON recurring_items(account_id);
@@ -0,0 +1,11 @@
+-- Display and time zone preferences. There are no user accounts, so the
+-- table holds a single row.
+CREATE TABLE IF NOT EXISTS preferences (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    locale VARCHAR(16) NOT NULL DEFAULT 'en-US',
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO preferences (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS base_amount(NUMERIC, CHAR(3), DATE);
+DROP FUNCTION IF EXISTS exchange_rate(CHAR(3), CHAR(3), DATE);
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE accounts DROP COLUMN IF EXISTS currency;
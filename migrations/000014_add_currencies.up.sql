@@ -0,0 +1,59 @@
+-- Amounts are in the currency of their account, or of the transaction when it
+-- has no account. Existing rows take the currency chosen in preferences.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency CHAR(3);
+UPDATE accounts SET currency = (SELECT currency FROM preferences WHERE id = 1) WHERE currency IS NULL;
+ALTER TABLE accounts ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency CHAR(3);
+UPDATE transactions SET currency = (SELECT currency FROM preferences WHERE id = 1) WHERE currency IS NULL;
+ALTER TABLE transactions ALTER COLUMN currency SET NOT NULL;
+
+-- Daily exchange rates: one unit of base_currency buys rate units of
+-- quote_currency on rate_date
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id SERIAL PRIMARY KEY,
+    base_currency CHAR(3) NOT NULL,
+    quote_currency CHAR(3) NOT NULL CHECK (quote_currency <> base_currency),
+    rate_date DATE NOT NULL,
+    rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (base_currency, quote_currency, rate_date)
+);
+
+CREATE INDEX idx_exchange_rates_quote ON exchange_rates(quote_currency, base_currency, rate_date);
+
+-- exchange_rate returns how many units of to_currency one unit of
+-- from_currency buys on on_date, using the latest rate on or before that day.
+-- Rates are used directly, inverted, or crossed through a shared base such as
+-- EUR for ECB rates. It returns NULL when no rate is known.
+CREATE OR REPLACE FUNCTION exchange_rate(from_currency CHAR(3), to_currency CHAR(3), on_date DATE)
+RETURNS NUMERIC
+LANGUAGE sql STABLE
+AS $$
+    SELECT CASE WHEN from_currency = to_currency THEN 1 ELSE COALESCE(
+        (SELECT CASE WHEN r.base_currency = from_currency THEN r.rate ELSE 1 / r.rate END
+         FROM exchange_rates r
+         WHERE ((r.base_currency = from_currency AND r.quote_currency = to_currency)
+             OR (r.base_currency = to_currency AND r.quote_currency = from_currency))
+           AND r.rate_date <= on_date
+         ORDER BY r.rate_date DESC
+         LIMIT 1),
+        (SELECT t.rate / f.rate
+         FROM exchange_rates f
+         JOIN exchange_rates t ON t.base_currency = f.base_currency AND t.rate_date = f.rate_date
+         WHERE f.quote_currency = from_currency AND t.quote_currency = to_currency
+           AND f.rate_date <= on_date
+         ORDER BY f.rate_date DESC
+         LIMIT 1)
+    ) END
+$$;
+
+-- base_amount converts an amount into the currency chosen in preferences
+CREATE OR REPLACE FUNCTION base_amount(amount NUMERIC, from_currency CHAR(3), on_date DATE)
+RETURNS NUMERIC
+LANGUAGE sql STABLE
+AS $$
+    SELECT ROUND(amount * exchange_rate(from_currency, (SELECT p.currency FROM preferences p WHERE p.id = 1), on_date), 2)
+$$;
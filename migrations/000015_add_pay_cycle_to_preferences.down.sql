@@ -0,0 +1,4 @@
+ALTER TABLE preferences DROP CONSTRAINT IF EXISTS preferences_pay_date_check;
+ALTER TABLE preferences DROP COLUMN IF EXISTS pay_date;
+ALTER TABLE preferences DROP COLUMN IF EXISTS period_start_day;
+ALTER TABLE preferences DROP COLUMN IF EXISTS pay_cycle;
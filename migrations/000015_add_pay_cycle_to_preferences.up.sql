@@ -0,0 +1,9 @@
+-- Budget periods run monthly from period_start_day, or weekly or every two
+-- weeks from pay_date, instead of following calendar months
+ALTER TABLE preferences ADD COLUMN IF NOT EXISTS pay_cycle VARCHAR(10) NOT NULL DEFAULT 'monthly'
+    CHECK (pay_cycle IN ('monthly', 'weekly', 'biweekly'));
+ALTER TABLE preferences ADD COLUMN IF NOT EXISTS period_start_day SMALLINT NOT NULL DEFAULT 1
+    CHECK (period_start_day BETWEEN 1 AND 31);
+ALTER TABLE preferences ADD COLUMN IF NOT EXISTS pay_date DATE;
+ALTER TABLE preferences ADD CONSTRAINT preferences_pay_date_check
+    CHECK (pay_cycle = 'monthly' OR pay_date IS NOT NULL);
@@ -0,0 +1,12 @@
+-- normalize_payee mirrors NormalizePayee in the models package, so payee
+-- aliases can be matched against every transaction in a single statement:
+-- lower case words split on anything that is not a letter or digit, with
+-- words containing digits dropped
+CREATE OR REPLACE FUNCTION normalize_payee(description TEXT)
+RETURNS TEXT
+LANGUAGE sql IMMUTABLE
+AS $$
+    SELECT COALESCE(string_agg(w.word, ' ' ORDER BY w.n), '')
+    FROM regexp_split_to_table(lower(description), '[^[:alpha:][:digit:]]+') WITH ORDINALITY AS w(word, n)
+    WHERE w.word <> '' AND w.word !~ '[[:digit:]]'
+$$;
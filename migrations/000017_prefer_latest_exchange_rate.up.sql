@@ -0,0 +1,32 @@
+-- exchange_rate used any direct rate before trying a cross rate through a
+-- shared base, however old the direct rate was. It now takes whichever
+-- candidate has the latest rate_date on or before on_date, preferring the
+-- direct rate when both are from the same day.
+CREATE OR REPLACE FUNCTION exchange_rate(from_currency CHAR(3), to_currency CHAR(3), on_date DATE)
+RETURNS NUMERIC
+LANGUAGE sql STABLE
+AS $$
+    SELECT CASE WHEN from_currency = to_currency THEN 1 ELSE (
+        SELECT c.rate
+        FROM (
+            (SELECT CASE WHEN r.base_currency = from_currency THEN r.rate ELSE 1 / r.rate END AS rate,
+                    r.rate_date, 0 AS priority
+             FROM exchange_rates r
+             WHERE ((r.base_currency = from_currency AND r.quote_currency = to_currency)
+                 OR (r.base_currency = to_currency AND r.quote_currency = from_currency))
+               AND r.rate_date <= on_date
+             ORDER BY r.rate_date DESC
+             LIMIT 1)
+            UNION ALL
+            (SELECT t.rate / f.rate AS rate, f.rate_date, 1 AS priority
+             FROM exchange_rates f
+             JOIN exchange_rates t ON t.base_currency = f.base_currency AND t.rate_date = f.rate_date
+             WHERE f.quote_currency = from_currency AND t.quote_currency = to_currency
+               AND f.rate_date <= on_date
+             ORDER BY f.rate_date DESC
+             LIMIT 1)
+        ) c
+        ORDER BY c.rate_date DESC, c.priority
+        LIMIT 1
+    ) END
+$$;
@@ -9,6 +9,110 @@ document.addEventListener('DOMContentLoaded', function() {
     
     let transactionIdToDelete = null;
 
+    // Ask for confirmation before submitting destructive forms. Inline
+    // onclick/onsubmit handlers are blocked by the Content-Security-Policy.
+    // With data-confirm-action, only ask when that action is selected.
+    document.querySelectorAll('form[data-confirm]').forEach(form => {
+        form.addEventListener('submit', function(e) {
+            const onlyFor = this.getAttribute('data-confirm-action');
+            if (onlyFor && this.elements['action'].value !== onlyFor) {
+                return;
+            }
+            if (!confirm(this.getAttribute('data-confirm'))) {
+                e.preventDefault();
+            }
+        });
+    });
+
+    // Bulk actions: select all rows and show only the inputs the chosen
+    // action needs
+    const selectAll = document.getElementById('select-all');
+    if (selectAll) {
+        selectAll.addEventListener('change', function() {
+            document.querySelectorAll('.select-row').forEach(box => {
+                box.checked = selectAll.checked;
+            });
+        });
+    }
+
+    const bulkAction = document.getElementById('bulk-action');
+    if (bulkAction) {
+        const updateBulkFields = function() {
+            document.querySelectorAll('.bulk-field').forEach(field => {
+                const active = field.getAttribute('data-action') === bulkAction.value;
+                field.style.display = active ? '' : 'none';
+                field.required = active;
+            });
+        };
+        bulkAction.addEventListener('change', updateBulkFields);
+        updateBulkFields();
+    }
+
+    // Category suggestions: while a new transaction is being entered, ask
+    // the server for the likely category and pre-select it, unless the
+    // user has picked one themselves
+    const categorySelect = document.querySelector('select[data-suggest-url]');
+    if (categorySelect) {
+        const form = categorySelect.form;
+        const hint = document.getElementById('category-suggestion');
+        // Tells the server the category was pre-selected, so rules may replace it
+        const suggested = document.getElementById('category_suggested');
+        let userChose = categorySelect.value !== '';
+        let autoSelected = false;
+        let timer = null;
+
+        categorySelect.addEventListener('change', function() {
+            userChose = categorySelect.value !== '';
+            autoSelected = false;
+            suggested.value = '';
+            hint.hidden = true;
+        });
+
+        const suggest = function() {
+            if (userChose) {
+                return;
+            }
+            const params = new URLSearchParams();
+            params.append('description', form.elements['description'].value);
+            params.append('amount', form.elements['amount'].value);
+            params.append('account_id', form.elements['account_id'].value);
+
+            fetch(categorySelect.getAttribute('data-suggest-url') + '?' + params.toString())
+                .then(response => response.ok ? response.json() : null)
+                .then(data => {
+                    if (!data || userChose) {
+                        return;
+                    }
+                    const suggestion = data.suggestion;
+                    if (suggestion) {
+                        categorySelect.value = String(suggestion.category_id);
+                        autoSelected = true;
+                        suggested.value = '1';
+                        hint.textContent = suggestion.source === 'rule'
+                            ? 'Selected by a rule'
+                            : 'Suggested from similar transactions (' + Math.round(suggestion.confidence * 100) + '% match)';
+                        hint.hidden = false;
+                    } else if (autoSelected) {
+                        categorySelect.value = '';
+                        autoSelected = false;
+                        suggested.value = '';
+                        hint.hidden = true;
+                    }
+                })
+                .catch(error => console.error('Category suggestion failed:', error));
+        };
+
+        ['description', 'amount', 'account_id'].forEach(name => {
+            const field = form.elements[name];
+            if (field) {
+                field.addEventListener('input', function() {
+                    clearTimeout(timer);
+                    timer = setTimeout(suggest, 300);
+                });
+            }
+        });
+    }
+
     // Handle transaction deletion
     const deleteButtons = document.querySelectorAll('.delete-transaction');
     deleteButtons.forEach(button => {
@@ -19,7 +123,7 @@ document.addEventListener('DOMContentLoaded', function() {
     });
 
     // Confirm deletion
-    confirmDeleteBtn.addEventListener('click', function() {
+    if (confirmDeleteBtn) confirmDeleteBtn.addEventListener('click', function() {
         if (transactionIdToDelete) {
             // Send DELETE request to the server
             fetch(`/transaction/${transactionIdToDelete}`, {
@@ -47,7 +151,7 @@ document.addEventListener('DOMContentLoaded', function() {
     });
 
     // Cancel deletion
-    cancelDeleteBtn.addEventListener('click', closeModal);
+    if (cancelDeleteBtn) cancelDeleteBtn.addEventListener('click', closeModal);
 
     // Close modal if clicked outside
     window.addEventListener('click', function(event) {